@@ -0,0 +1,130 @@
+package resty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/porebric/logger"
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/internal/cleanup"
+	"github.com/porebric/resty/jobs"
+	"github.com/porebric/resty/middleware"
+	"github.com/porebric/resty/requests"
+	"github.com/porebric/resty/responses"
+)
+
+// Jobs is a worker pool whose job states are served on path/{id}.
+type Jobs struct {
+	*jobs.Pool
+	path string
+}
+
+// NewJobs starts a worker pool, registers its status route and drains it on shutdown.
+func NewJobs(r Router, path string, opts jobs.Options) *Jobs {
+	j := &Jobs{Pool: jobs.NewPool(opts), path: "/" + strings.Trim(path, "/")}
+
+	EndpointWithSpec(r, RouteSpec{Path: j.path + "/{id}", Methods: []string{http.MethodGet}, Group: "jobs", Description: "job status"},
+		func(ctx context.Context, r *http.Request) (context.Context, *JobRequest, error) {
+			return ctx, &JobRequest{ID: mux.Vars(r)["id"]}, nil
+		}, j.status)
+
+	r.OnShutdown(j.Close)
+	return j
+}
+
+// Location returns the status route of the job.
+func (j *Jobs) Location(id string) string {
+	return j.path + "/" + id
+}
+
+func (j *Jobs) status(ctx context.Context, req *JobRequest) (responses.Response, int) {
+	job, err := j.Store().Get(ctx, req.ID)
+	if err != nil {
+		return storeError(ctx, err, errors.ErrorUnableGetData)
+	}
+
+	resp := &responses.JSON[*jobs.Job]{Data: job}
+	if !job.Done() {
+		resp.SetHeader("Retry-After", "1")
+	}
+	return resp, http.StatusOK
+}
+
+// AsyncEndpoint registers an endpoint whose action runs on the worker pool of j. The request is initialized,
+// validated and passed through mm before answering 202 with the job and a Location of its status route.
+// The rendered response of action becomes the result of the job. Request cleanups, like the files of
+// upload.Bind, are moved to the job and run after action with its status.
+func AsyncEndpoint[R requests.Request](r Router, spec RouteSpec, j *Jobs, req func(ctx context.Context, r *http.Request) (context.Context, R, error), action func(context.Context, R) (responses.Response, int), mm ...func() middleware.Middleware) {
+	EndpointWithSpec(r, spec, req, func(ctx context.Context, req R) (responses.Response, int) {
+		ctx = cleanup.Detach(ctx)
+
+		job, err := j.Submit(ctx, func(ctx context.Context) (int, json.RawMessage, error) {
+			defer runCleanup(ctx)
+
+			resp, code := action(ctx, req)
+			code = responseStatus(resp, code)
+			cleanup.SetStatus(ctx, code)
+			body, err := responseBody(resp)
+			return code, body, err
+		})
+		if err != nil {
+			runCleanup(ctx)
+
+			if stderrors.Is(err, jobs.ErrQueueFull) || stderrors.Is(err, jobs.ErrClosed) {
+				return errors.GetCustomError("", errors.ErrorServiceUnavailable)
+			}
+			logger.Error(ctx, err, "submit job")
+			return errors.GetCustomError("", errors.ErrorCritical)
+		}
+
+		resp := &responses.JSON[*jobs.Job]{Data: job}
+		resp.SetHeader("Location", j.Location(job.ID))
+		return resp, http.StatusAccepted
+	}, mm...)
+}
+
+type JobRequest struct {
+	ID string
+}
+
+func (r *JobRequest) Validate() (bool, string, string) {
+	return true, "", ""
+}
+
+func (r *JobRequest) Methods() []string {
+	return []string{http.MethodGet}
+}
+
+func (r *JobRequest) Path() (string, bool) {
+	return "", false
+}
+
+func (r *JobRequest) String() string {
+	return fmt.Sprintf(`{"id":%q}`, r.ID)
+}
+
+func runCleanup(ctx context.Context) {
+	if err := cleanup.Run(ctx); err != nil {
+		logger.Warn(ctx, "job cleanup", "error", err)
+	}
+}
+
+// responseBody renders resp as JSON. Bodies that are not JSON are returned as a JSON string.
+func responseBody(resp responses.Response) (json.RawMessage, error) {
+	rec := &batchRecorder{header: make(http.Header)}
+	if err := resp.PrepareResponse(rec); err != nil {
+		return nil, err
+	}
+
+	body := bytes.TrimSpace(rec.body.Bytes())
+	if len(body) == 0 || json.Valid(body) {
+		return body, nil
+	}
+	return json.Marshal(string(body))
+}
@@ -0,0 +1,95 @@
+package resty
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/porebric/resty/internal/cleanup"
+	"github.com/porebric/resty/jobs"
+	"github.com/porebric/resty/responses"
+)
+
+func TestAsyncEndpointRunsCleanupsAfterJob(t *testing.T) {
+	r := newTestRouter()
+	j := NewJobs(r, "/jobs", jobs.Options{Workers: 1})
+
+	var (
+		cleaned    atomic.Bool
+		sawCleaned atomic.Bool
+		release    = make(chan struct{})
+	)
+	AsyncEndpoint(r, RouteSpec{Path: "/import", Methods: []string{http.MethodPost}}, j,
+		func(ctx context.Context, _ *http.Request) (context.Context, *echoRequest, error) {
+			cleanup.Add(ctx, func() error {
+				cleaned.Store(true)
+				return nil
+			})
+			return ctx, &echoRequest{Text: "file"}, nil
+		},
+		func(_ context.Context, req *echoRequest) (responses.Response, int) {
+			<-release
+			sawCleaned.Store(cleaned.Load())
+			return &responses.JSON[string]{Data: req.Text}, http.StatusOK
+		})
+
+	w := httptest.NewRecorder()
+	r.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/import", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if cleaned.Load() {
+		t.Fatal("cleanup ran when the request ended")
+	}
+
+	close(release)
+	if err := j.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if sawCleaned.Load() {
+		t.Fatal("cleanup ran before the job")
+	}
+	if !cleaned.Load() {
+		t.Fatal("cleanup did not run after the job")
+	}
+}
+
+func TestAsyncEndpointUsesHeadStatus(t *testing.T) {
+	r := newTestRouter()
+	j := NewJobs(r, "/jobs", jobs.Options{Workers: 1})
+
+	AsyncEndpoint(r, RouteSpec{Path: "/import", Methods: []string{http.MethodPost}}, j,
+		func(ctx context.Context, _ *http.Request) (context.Context, *echoRequest, error) {
+			return ctx, new(echoRequest), nil
+		},
+		func(context.Context, *echoRequest) (responses.Response, int) {
+			resp := &responses.JSON[string]{Data: "source not found"}
+			resp.Status = http.StatusNotFound
+			return resp, http.StatusOK
+		})
+
+	w := httptest.NewRecorder()
+	r.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/import", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	var submitted jobs.Job
+	if err := json.Unmarshal(w.Body.Bytes(), &submitted); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := j.Store().Get(context.Background(), submitted.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != jobs.StatusFailed || job.Code != http.StatusNotFound {
+		t.Fatalf("job = %+v, want failed with 404", job)
+	}
+}
@@ -0,0 +1,100 @@
+package resty
+
+import (
+	"context"
+	"strings"
+
+	"github.com/porebric/resty/authz"
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/middleware"
+	"github.com/porebric/resty/requests"
+)
+
+// Authorize requires p for every endpoint of group, in addition to the policies of the endpoint.
+// It is checked after the middlewares of the endpoint, so they can resolve the principal.
+func (r *router) Authorize(group string, p authz.Policy) {
+	if r.groupPolicies == nil {
+		r.groupPolicies = make(map[string]authz.Policy)
+	}
+	r.groupPolicies[group] = p
+}
+
+// DenyByDefault makes endpoints without any policy answer errors.ErrorInvalidAccess.
+// Endpoints open on purpose declare authz.Public.
+func (r *router) DenyByDefault() {
+	r.denyByDefault = true
+}
+
+func (r *router) authorization(group string) (authz.Policy, bool) {
+	return r.groupPolicies[group], r.denyByDefault
+}
+
+// endpointPolicies returns the policies of the authz middlewares in mm.
+func endpointPolicies(mm []func() middleware.Middleware) []authz.Policy {
+	var policies []authz.Policy
+	for _, m := range mm {
+		if a, ok := m().(interface{ Policy() authz.Policy }); ok {
+			policies = append(policies, a.Policy())
+		}
+	}
+	return policies
+}
+
+// describePolicies documents the policies checked for e.
+func (r *router) describePolicies(e *endpoint) string {
+	names := make([]string, 0, len(e.policies)+1)
+	for _, p := range e.policies {
+		names = append(names, p.String())
+	}
+	if p, ok := r.groupPolicies[e.spec.Group]; ok {
+		names = append(names, p.String())
+	}
+
+	switch {
+	case len(names) != 0:
+		return strings.Join(names, ", ")
+	case r.denyByDefault && e.checksPolicies:
+		return "deny"
+	default:
+		return ""
+	}
+}
+
+// routeAuthorization returns the last middleware of an endpoint: the policy of its group and the deny-by-default mode.
+// Routers implemented outside the package have no group policies.
+func routeAuthorization(r Router, group string, declared bool) func() middleware.Middleware {
+	rr := asRouter(r)
+	return func() middleware.Middleware {
+		return &routeAuthorizer{router: rr, group: group, declared: declared}
+	}
+}
+
+type routeAuthorizer struct {
+	next     middleware.Middleware
+	router   *router
+	group    string
+	declared bool
+}
+
+func (a *routeAuthorizer) Execute(ctx context.Context, req requests.Request) (context.Context, int32, string) {
+	if a.router == nil {
+		return a.next.Execute(ctx, req)
+	}
+
+	policy, deny := a.router.authorization(a.group)
+	switch {
+	case policy != nil:
+		if code := authz.Check(ctx, policy, req); code != errors.ErrorNoError {
+			return ctx, code, ""
+		}
+	case deny && !a.declared:
+		authz.Deny(ctx, "", "deny", req)
+		return ctx, errors.ErrorInvalidAccess, ""
+	}
+
+	return a.next.Execute(ctx, req)
+}
+
+func (a *routeAuthorizer) SetNext(next middleware.Middleware) {
+	a.next = next
+}
@@ -0,0 +1,132 @@
+package authz
+
+import (
+	"context"
+	"strings"
+
+	"github.com/porebric/logger"
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/requests"
+)
+
+// Policy decides whether p may execute req. p is nil for anonymous requests.
+// String describes the policy in the route documentation.
+type Policy interface {
+	Allow(ctx context.Context, p Principal, req requests.Request) bool
+	String() string
+}
+
+type policy struct {
+	name  string
+	allow func(ctx context.Context, p Principal, req requests.Request) bool
+}
+
+func (p *policy) Allow(ctx context.Context, principal Principal, req requests.Request) bool {
+	return p.allow(ctx, principal, req)
+}
+
+func (p *policy) String() string {
+	return p.name
+}
+
+// New returns a policy described by name.
+func New(name string, allow func(ctx context.Context, p Principal, req requests.Request) bool) Policy {
+	return &policy{name: name, allow: allow}
+}
+
+// Public allows every request, including anonymous ones. It marks endpoints open on purpose in deny-by-default mode.
+func Public() Policy {
+	return New("public", func(context.Context, Principal, requests.Request) bool {
+		return true
+	})
+}
+
+// Authenticated allows every request with a Principal.
+func Authenticated() Policy {
+	return New("authenticated", func(_ context.Context, p Principal, _ requests.Request) bool {
+		return p != nil
+	})
+}
+
+func Role(role string) Policy {
+	return New("role:"+role, func(_ context.Context, p Principal, _ requests.Request) bool {
+		return p != nil && p.HasRole(role)
+	})
+}
+
+func Scope(scope string) Policy {
+	return New("scope:"+scope, func(_ context.Context, p Principal, _ requests.Request) bool {
+		return p != nil && p.HasScope(scope)
+	})
+}
+
+func Permission(permission string) Policy {
+	return New("permission:"+permission, func(_ context.Context, p Principal, _ requests.Request) bool {
+		return p != nil && p.HasPermission(permission)
+	})
+}
+
+// Resource checks the bound request, like the owner of the requested object. Requests of another type are denied.
+func Resource[R requests.Request](name string, fn func(ctx context.Context, p Principal, req R) bool) Policy {
+	return New("resource:"+name, func(ctx context.Context, p Principal, req requests.Request) bool {
+		r, ok := req.(R)
+		return ok && p != nil && fn(ctx, p, r)
+	})
+}
+
+// Any allows the request if one of pp does.
+func Any(pp ...Policy) Policy {
+	return New(combinedName("any", pp), func(ctx context.Context, p Principal, req requests.Request) bool {
+		for _, policy := range pp {
+			if policy.Allow(ctx, p, req) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// All allows the request if every policy of pp does.
+func All(pp ...Policy) Policy {
+	return New(combinedName("all", pp), func(ctx context.Context, p Principal, req requests.Request) bool {
+		for _, policy := range pp {
+			if !policy.Allow(ctx, p, req) {
+				return false
+			}
+		}
+		return len(pp) != 0
+	})
+}
+
+func combinedName(op string, pp []Policy) string {
+	names := make([]string, 0, len(pp))
+	for _, p := range pp {
+		names = append(names, p.String())
+	}
+	return op + "(" + strings.Join(names, ", ") + ")"
+}
+
+// Check evaluates policy for the request and logs denials. It returns errors.ErrorNoError if the request is allowed.
+func Check(ctx context.Context, policy Policy, req requests.Request) int32 {
+	p, _ := FromContext(ctx)
+	if policy.Allow(ctx, p, req) {
+		return errors.ErrorNoError
+	}
+
+	if p == nil {
+		Deny(ctx, "", policy.String(), req)
+		return errors.ErrorUserUnauthorized
+	}
+
+	Deny(ctx, p.ID(), policy.String(), req)
+	return errors.ErrorInvalidAccess
+}
+
+// Deny writes the audit log entry of a denied request.
+func Deny(ctx context.Context, principal, policy string, req requests.Request) {
+	var content string
+	if req != nil {
+		content = req.String()
+	}
+	logger.Warn(ctx, "access denied", "principal", principal, "policy", policy, "content", content)
+}
@@ -0,0 +1,115 @@
+// Package authz checks declared policies against the Principal of the request.
+//
+// A middleware earlier in the chain, like Resolve, puts the Principal into the context, Require checks it:
+//
+//	resolve := authz.Resolve(func(ctx context.Context) (authz.Principal, bool) {
+//		return jwt.Claims[*authz.Claims](ctx)
+//	})
+//	resty.Endpoint(router, initReq, action, auth, resolve, authz.Require(authz.Any(authz.Role("admin"), authz.Scope("users:write"))))
+//
+// Requests without a Principal fail with errors.ErrorUserUnauthorized, denied ones with errors.ErrorInvalidAccess.
+package authz
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/middleware"
+	"github.com/porebric/resty/requests"
+)
+
+type Principal interface {
+	ID() string
+	HasRole(role string) bool
+	HasScope(scope string) bool
+	HasPermission(permission string) bool
+}
+
+// Claims is a Principal decoded from token claims. scope is the space separated OAuth 2 claim.
+type Claims struct {
+	Subject     string   `json:"sub"`
+	Roles       []string `json:"roles,omitempty"`
+	Scope       string   `json:"scope,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+func (c *Claims) ID() string {
+	return c.Subject
+}
+
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(strings.Fields(c.Scope), scope)
+}
+
+func (c *Claims) HasPermission(permission string) bool {
+	return slices.Contains(c.Permissions, permission)
+}
+
+type ctxKey struct{}
+
+func ToContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Principal)
+	return p, ok && p != nil
+}
+
+// Resolve returns a middleware factory putting the Principal returned by fn into the context.
+// Requests without one pass, the policies decide.
+func Resolve(fn func(ctx context.Context) (Principal, bool)) func() middleware.Middleware {
+	return func() middleware.Middleware {
+		return &Resolver{fn: fn}
+	}
+}
+
+type Resolver struct {
+	next middleware.Middleware
+	fn   func(ctx context.Context) (Principal, bool)
+}
+
+func (r *Resolver) Execute(ctx context.Context, req requests.Request) (context.Context, int32, string) {
+	if p, ok := r.fn(ctx); ok && p != nil {
+		ctx = ToContext(ctx, p)
+	}
+	return r.next.Execute(ctx, req)
+}
+
+func (r *Resolver) SetNext(next middleware.Middleware) {
+	r.next = next
+}
+
+// Require returns a middleware factory allowing only the requests p allows.
+func Require(p Policy) func() middleware.Middleware {
+	return func() middleware.Middleware {
+		return &Authorizer{policy: p}
+	}
+}
+
+type Authorizer struct {
+	next   middleware.Middleware
+	policy Policy
+}
+
+func (a *Authorizer) Execute(ctx context.Context, req requests.Request) (context.Context, int32, string) {
+	if code := Check(ctx, a.policy, req); code != errors.ErrorNoError {
+		return ctx, code, ""
+	}
+	return a.next.Execute(ctx, req)
+}
+
+func (a *Authorizer) SetNext(next middleware.Middleware) {
+	a.next = next
+}
+
+// Policy returns the checked policy, used to document the endpoint.
+func (a *Authorizer) Policy() Policy {
+	return a.policy
+}
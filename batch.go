@@ -0,0 +1,313 @@
+package resty
+
+import (
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/porebric/logger"
+	"github.com/porebric/resty/errors"
+)
+
+type BatchOptions struct {
+	// MaxRequests limits the number of sub-requests, 20 by default.
+	MaxRequests int
+	// MaxBodySize limits the batch body, 1 MB by default.
+	MaxBodySize int64
+	// Concurrency limits the sub-requests executed at the same time, 4 by default.
+	Concurrency int
+	// MaxResponseSize limits the bodies of all sub-responses together, 8 MB by default. Sub-requests answering
+	// past the limit get a 413 result.
+	MaxResponseSize int64
+}
+
+// BatchRequest is a single sub-request. It runs after all requests listed in DependsOn have succeeded.
+type BatchRequest struct {
+	ID        string            `json:"id"`
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      json.RawMessage   `json:"body,omitempty"`
+	DependsOn []string          `json:"depends_on,omitempty"`
+}
+
+// BatchResponse keeps every value of repeated headers, like Set-Cookie.
+type BatchResponse struct {
+	ID      string          `json:"id"`
+	Status  int             `json:"status"`
+	Headers http.Header     `json:"headers,omitempty"`
+	Body    json.RawMessage `json:"body,omitempty"`
+}
+
+// EnableBatch serves POST path with an array of sub-requests, dispatched through the router with the headers
+// of the batch request, and answers with their results in the same order.
+func (r *router) EnableBatch(path string, opts BatchOptions) {
+	if opts.MaxRequests <= 0 {
+		opts.MaxRequests = 20
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = 1 << 20
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.MaxResponseSize <= 0 {
+		opts.MaxResponseSize = 8 << 20
+	}
+
+	handle(r, &endpoint{
+		spec:    RouteSpec{Path: path, Methods: []string{http.MethodPost}, Description: "batch of sub-requests"},
+		request: "batch",
+	}, func(w http.ResponseWriter, req *http.Request) {
+		ctx := logger.ToContext(req.Context(), r.logFn())
+		w.Header().Set("Content-Type", "application/json")
+
+		var items []BatchRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, opts.MaxBodySize)).Decode(&items); err != nil {
+			writeBatchError(w, errors.ErrorInvalidRequest, "invalid batch body")
+			return
+		}
+		if len(items) > opts.MaxRequests {
+			writeBatchError(w, errors.ErrorRequestTooLarge, fmt.Sprintf("at most %d requests are allowed", opts.MaxRequests))
+			return
+		}
+		if msg := checkBatch(items, path); msg != "" {
+			writeBatchError(w, errors.ErrorInvalidRequest, msg)
+			return
+		}
+
+		budget := new(atomic.Int64)
+		budget.Store(opts.MaxResponseSize)
+
+		results := r.runBatch(req, items, opts.Concurrency, budget)
+		if req.Context().Err() != nil {
+			logger.Warn(ctx, "batch request cancelled", "path", path, "requests", len(items))
+			return
+		}
+		logger.Info(ctx, "batch request", "path", path, "requests", len(items))
+
+		_ = json.NewEncoder(w).Encode(results)
+	})
+}
+
+func checkBatch(items []BatchRequest, batchPath string) string {
+	ids := make(map[string]bool, len(items))
+	for i, item := range items {
+		if item.ID == "" {
+			return fmt.Sprintf("request %d: id is required", i)
+		}
+		if ids[item.ID] {
+			return fmt.Sprintf("request %s: duplicate id", item.ID)
+		}
+		ids[item.ID] = true
+
+		u, err := url.Parse(item.Path)
+		if item.Method == "" || err != nil || !strings.HasPrefix(u.Path, "/") {
+			return fmt.Sprintf("request %s: method and absolute path are required", item.ID)
+		}
+		// compared like the router matches it, so encoded or unclean forms of the path are caught too
+		if path.Clean(u.Path) == path.Clean(batchPath) {
+			return fmt.Sprintf("request %s: batches cannot be nested", item.ID)
+		}
+	}
+
+	for _, item := range items {
+		for _, dep := range item.DependsOn {
+			if !ids[dep] {
+				return fmt.Sprintf("request %s: unknown dependency %s", item.ID, dep)
+			}
+		}
+	}
+	return ""
+}
+
+// runBatch executes the items in waves: every wave runs the items whose dependencies are finished.
+// Items depending on a failed request, or on a cycle, are answered with 424. No further waves are started
+// once the client is gone.
+func (r *router) runBatch(req *http.Request, items []BatchRequest, concurrency int, budget *atomic.Int64) []BatchResponse {
+	var (
+		results = make([]BatchResponse, len(items))
+		status  = make(map[string]int, len(items))
+		pending = make(map[int]bool, len(items))
+	)
+	for i := range items {
+		pending[i] = true
+	}
+
+	for len(pending) != 0 && req.Context().Err() == nil {
+		var (
+			wave     []int
+			progress bool
+		)
+		for i := range pending {
+			ready, failed := true, false
+			for _, dep := range items[i].DependsOn {
+				code, done := status[dep]
+				if !done {
+					ready = false
+				} else if code >= http.StatusBadRequest {
+					failed = true
+				}
+			}
+
+			switch {
+			case ready && failed:
+				results[i] = failedDependency(items[i].ID, "dependency failed")
+				status[items[i].ID] = results[i].Status
+				delete(pending, i)
+				progress = true
+			case ready:
+				wave = append(wave, i)
+			}
+		}
+
+		if len(wave) == 0 {
+			if !progress {
+				for i := range pending {
+					results[i] = failedDependency(items[i].ID, "dependency cycle")
+					delete(pending, i)
+				}
+			}
+			continue
+		}
+
+		var (
+			wg  sync.WaitGroup
+			sem = make(chan struct{}, concurrency)
+		)
+		for _, i := range wave {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				results[i] = r.dispatch(req, items[i], budget)
+			}(i)
+		}
+		wg.Wait()
+
+		for _, i := range wave {
+			status[items[i].ID] = results[i].Status
+			delete(pending, i)
+		}
+	}
+
+	return results
+}
+
+// dispatch runs item with the context of the batch request, its body is charged to budget.
+func (r *router) dispatch(parent *http.Request, item BatchRequest, budget *atomic.Int64) BatchResponse {
+	var body io.Reader = http.NoBody
+	if len(item.Body) != 0 {
+		body = bytes.NewReader(item.Body)
+	}
+
+	sub, err := http.NewRequestWithContext(parent.Context(), strings.ToUpper(item.Method), item.Path, body)
+	if err != nil {
+		resp, code := errors.GetCustomError("invalid request", errors.ErrorInvalidRequest)
+		encoded, _ := json.Marshal(resp)
+		return BatchResponse{ID: item.ID, Status: code, Body: encoded}
+	}
+
+	sub.Header = parent.Header.Clone()
+	sub.Header.Del("Content-Length")
+	for k, v := range item.Headers {
+		sub.Header.Set(k, v)
+	}
+	if len(item.Body) != 0 && sub.Header.Get("Content-Type") == "" {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+	sub.RemoteAddr = parent.RemoteAddr
+	sub.Host = parent.Host
+
+	rec := &batchRecorder{header: make(http.Header), budget: budget}
+	r.router.ServeHTTP(rec, sub)
+
+	if rec.exceeded {
+		resp, code := errors.GetCustomError("batch response too large", errors.ErrorRequestTooLarge)
+		encoded, _ := json.Marshal(resp)
+		return BatchResponse{ID: item.ID, Status: code, Body: encoded}
+	}
+
+	resp := BatchResponse{ID: item.ID, Status: rec.Status(), Headers: rec.header}
+
+	switch data := bytes.TrimSpace(rec.body.Bytes()); {
+	case len(data) == 0:
+	case json.Valid(data):
+		resp.Body = data
+	default:
+		resp.Body, _ = json.Marshal(string(data))
+	}
+	return resp
+}
+
+func failedDependency(id, msg string) BatchResponse {
+	body, _ := json.Marshal(map[string]string{"message": msg})
+	return BatchResponse{ID: id, Status: http.StatusFailedDependency, Body: body}
+}
+
+func writeBatchError(w http.ResponseWriter, code int32, msg string) {
+	resp, httpCode := errors.GetCustomError(msg, code)
+	w.WriteHeader(httpCode)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+var errBatchTooLarge = stderrors.New("batch response too large")
+
+// batchRecorder collects the response of a sub-request while the budget shared by the batch lasts.
+// Without a budget it is unlimited, like for rendering job results. An exceeded response gives back what it
+// charged, the error replacing it is small.
+type batchRecorder struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	budget   *atomic.Int64
+	charged  int64
+	exceeded bool
+}
+
+func (r *batchRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *batchRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+func (r *batchRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if r.exceeded {
+		return 0, errBatchTooLarge
+	}
+	if r.budget != nil {
+		r.charged += int64(len(b))
+		if r.budget.Add(-int64(len(b))) < 0 {
+			r.budget.Add(r.charged)
+			r.exceeded, r.charged = true, 0
+			r.body.Reset()
+			return 0, errBatchTooLarge
+		}
+	}
+	return r.body.Write(b)
+}
+
+func (r *batchRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
@@ -0,0 +1,79 @@
+package resty
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/porebric/resty/responses"
+)
+
+func serveBatch(t *testing.T, r *router, body string) (int, []BatchResponse) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(body)))
+
+	var results []BatchResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+			t.Fatalf("decode %q: %v", w.Body.String(), err)
+		}
+	}
+	return w.Code, results
+}
+
+func TestBatchRejectsNestedBatch(t *testing.T) {
+	r := newTestRouter()
+	r.EnableBatch("/batch", BatchOptions{})
+
+	for _, p := range []string{"/batch", "/%62atch", "/x/../batch", "//batch?a=1"} {
+		code, _ := serveBatch(t, r, `[{"id":"1","method":"POST","path":"`+p+`","body":[]}]`)
+		if code == http.StatusOK {
+			t.Errorf("%s: nested batch accepted", p)
+		}
+	}
+}
+
+func TestBatchKeepsRepeatedHeaders(t *testing.T) {
+	r := newTestRouter()
+	r.EnableBatch("/batch", BatchOptions{})
+	EndpointWithSpec(r, RouteSpec{Path: "/login", Methods: []string{http.MethodPost}},
+		func(ctx context.Context, _ *http.Request) (context.Context, *echoRequest, error) {
+			return ctx, new(echoRequest), nil
+		},
+		func(ctx context.Context, _ *echoRequest) (responses.Response, int) {
+			resp := &responses.JSON[string]{Data: "ok"}
+			resp.AddCookie(&http.Cookie{Name: "a", Value: "1"})
+			resp.AddCookie(&http.Cookie{Name: "b", Value: "2"})
+			return resp, http.StatusOK
+		})
+
+	_, results := serveBatch(t, r, `[{"id":"1","method":"POST","path":"/login"}]`)
+	if got := results[0].Headers.Values("Set-Cookie"); len(got) != 2 {
+		t.Fatalf("Set-Cookie = %v, want both cookies", got)
+	}
+}
+
+func TestBatchRefundsExceededResponses(t *testing.T) {
+	budget := new(atomic.Int64)
+	budget.Store(100)
+
+	rec := &batchRecorder{header: make(http.Header), budget: budget}
+	_, _ = rec.Write(make([]byte, 60))
+	if _, err := rec.Write(make([]byte, 60)); err != errBatchTooLarge {
+		t.Fatalf("err = %v, want errBatchTooLarge", err)
+	}
+	if n := budget.Load(); n != 100 {
+		t.Fatalf("budget = %d, want the 100 bytes back", n)
+	}
+
+	rec = &batchRecorder{header: make(http.Header), budget: budget}
+	if _, err := rec.Write(make([]byte, 80)); err != nil {
+		t.Fatalf("next response: %v", err)
+	}
+}
@@ -0,0 +1,337 @@
+// Package cache caches rendered action responses in a Store.
+//
+// Actions are wrapped, the endpoint and its middlewares stay the same:
+//
+//	c := cache.New(cache.NewLRU(0))
+//	resty.Endpoint(router, initReq, cache.Action(c, "users.get", cache.Options[*GetUser]{TTL: time.Minute}, getUser))
+//
+// Only GET and HEAD requests are cached, together with the responses answered with a cacheable status and
+// without cookies. Cache-Control of the request (no-store, no-cache, max-age=0) and of the response (no-store,
+// no-cache, private, max-age, s-maxage, stale-while-revalidate) is honoured.
+//
+// Like a shared cache, responses to requests with credentials (Authorization, X-API-Key or cookies) are only
+// cached if they are Cache-Control: public, and kept apart from the responses to anonymous requests.
+// Responses depending on the user need public and the user in Key or Vary.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/porebric/logger"
+	"github.com/porebric/resty/requests"
+	"github.com/porebric/resty/responses"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var cacheRequests = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_cache_requests_total",
+		Help: "The number of cached action calls, tracked by route and result: hit, stale, miss or bypass.",
+	},
+	[]string{"route", "result"},
+)
+
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+type Cache struct {
+	store Store
+	group group
+}
+
+func New(store Store) *Cache {
+	return &Cache{store: store}
+}
+
+type Options[R requests.Request] struct {
+	// TTL is how long responses are fresh, 1 minute by default. max-age of the response wins.
+	TTL time.Duration
+	// StaleWhileRevalidate serves expired responses for this long while one call refreshes them in the background.
+	StaleWhileRevalidate time.Duration
+	// Key selects the request fields identifying a response, R.String() by default.
+	Key func(ctx context.Context, req R) string
+	// Vary lists the request headers that are part of the key. They are added to the Vary header.
+	Vary []string
+}
+
+// Action wraps action with the cache. route prefixes the keys and labels the metrics.
+// A stale response is refreshed with the request it was served for, after that request has finished.
+func Action[R requests.Request](c *Cache, route string, opts Options[R], action func(context.Context, R) (responses.Response, int)) func(context.Context, R) (responses.Response, int) {
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+	if opts.Key == nil {
+		opts.Key = func(_ context.Context, req R) string {
+			return req.String()
+		}
+	}
+
+	return func(ctx context.Context, req R) (responses.Response, int) {
+		r := requests.HTTPRequest(ctx)
+		if r != nil && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			cacheRequests.WithLabelValues(route, "bypass").Inc()
+			return action(ctx, req)
+		}
+
+		reqDirectives := directives(r, nil)
+		if _, ok := reqDirectives["no-store"]; ok {
+			cacheRequests.WithLabelValues(route, "bypass").Inc()
+			return action(ctx, req)
+		}
+		_, revalidate := reqDirectives["no-cache"]
+		if v, ok := reqDirectives["max-age"]; ok && v == "0" {
+			revalidate = true
+		}
+
+		credentialed := hasCredentials(r)
+		key := cacheKey(route, opts.Key(ctx, req), opts.Vary, r, credentialed)
+
+		fetch := func(ctx context.Context) (*Entry, responses.Response, int) {
+			resp, code := action(ctx, req)
+			entry, ok := render(resp, code, opts.TTL, opts.StaleWhileRevalidate, credentialed)
+			if !ok {
+				return nil, resp, code
+			}
+			if err := c.store.Set(ctx, key, entry); err != nil {
+				logger.Warn(ctx, "cache set", "route", route, "error", err)
+			}
+			return entry, nil, 0
+		}
+
+		if !revalidate {
+			entry, err := c.store.Get(ctx, key)
+			if err != nil {
+				logger.Warn(ctx, "cache get", "route", route, "error", err)
+			}
+
+			now := time.Now()
+			switch {
+			case entry == nil:
+			case entry.fresh(now):
+				cacheRequests.WithLabelValues(route, "hit").Inc()
+				return replay(entry, opts.Vary, "HIT", now)
+			case now.Before(entry.ExpiresAt()):
+				if !c.group.running(key) {
+					bg := context.WithoutCancel(ctx)
+					go c.group.do(key, func() (*Entry, error) {
+						entry, _, _ := fetch(bg)
+						return entry, nil
+					})
+				}
+				cacheRequests.WithLabelValues(route, "stale").Inc()
+				return replay(entry, opts.Vary, "STALE", now)
+			}
+		}
+
+		cacheRequests.WithLabelValues(route, "miss").Inc()
+
+		var (
+			own      *Entry
+			ownResp  responses.Response
+			ownCode  int
+			executed bool
+		)
+		entry, _, _ := c.group.do(key, func() (*Entry, error) {
+			executed = true
+			own, ownResp, ownCode = fetch(ctx)
+			return own, nil
+		})
+
+		switch {
+		case executed && own == nil:
+			return withVary(ownResp, opts.Vary), ownCode
+		case executed || entry != nil:
+			return replay(entry, opts.Vary, "MISS", time.Now())
+		default:
+			// the collapsed call was not cacheable, its response may be private to the other caller
+			return action(ctx, req)
+		}
+	}
+}
+
+// Invalidate removes the cached responses of key, the value the Key option returns for a request,
+// for anonymous requests and requests with credentials.
+func (c *Cache) Invalidate(ctx context.Context, route, key string, vary []string, r *http.Request) error {
+	if err := c.store.Delete(ctx, cacheKey(route, key, vary, r, false)); err != nil {
+		return err
+	}
+	return c.store.Delete(ctx, cacheKey(route, key, vary, r, true))
+}
+
+// hasCredentials reports whether r may be answered for a particular user.
+func hasCredentials(r *http.Request) bool {
+	return r != nil && (r.Header.Get("Authorization") != "" || r.Header.Get("X-API-Key") != "" || r.Header.Get("Cookie") != "")
+}
+
+func cacheKey(route, key string, vary []string, r *http.Request, credentialed bool) string {
+	h := sha256.New()
+	h.Write([]byte(route))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	if credentialed {
+		h.Write([]byte{0, 1})
+	}
+	for _, name := range vary {
+		h.Write([]byte{0})
+		if r != nil {
+			h.Write([]byte(strings.Join(r.Header.Values(name), ",")))
+		}
+	}
+	return route + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// render records resp into an entry. It reports false for responses that must not be cached, and for
+// responses to credentialed requests that are not public.
+func render(resp responses.Response, code int, ttl, stale time.Duration, credentialed bool) (*Entry, bool) {
+	if _, ok := resp.(responses.RequestResponse); ok || resp == nil {
+		return nil, false
+	}
+
+	rec := &recorder{header: http.Header{"Content-Type": {"application/json"}}}
+	if h, ok := resp.(responses.Headed); ok {
+		head := h.ResponseHead()
+		if head.Status != 0 {
+			code = head.Status
+		}
+		head.Apply(rec)
+	}
+	if code == 0 {
+		code = http.StatusOK
+	}
+
+	if !cacheableStatus[code] || rec.header.Get("Set-Cookie") != "" {
+		return nil, false
+	}
+
+	respDirectives := directives(nil, rec.header)
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := respDirectives[d]; ok {
+			return nil, false
+		}
+	}
+	if _, ok := respDirectives["public"]; credentialed && !ok {
+		return nil, false
+	}
+	if v, ok := respDirectives["s-maxage"]; ok {
+		ttl = seconds(v, ttl)
+	} else if v, ok := respDirectives["max-age"]; ok {
+		ttl = seconds(v, ttl)
+	}
+	if v, ok := respDirectives["stale-while-revalidate"]; ok {
+		stale = seconds(v, stale)
+	}
+	if ttl <= 0 {
+		return nil, false
+	}
+
+	if err := resp.PrepareResponse(rec); err != nil {
+		return nil, false
+	}
+
+	return &Entry{
+		Status:   code,
+		Header:   rec.header,
+		Body:     rec.body.Bytes(),
+		StoredAt: time.Now(),
+		FreshFor: ttl,
+		StaleFor: stale,
+	}, true
+}
+
+func replay(e *Entry, vary []string, result string, now time.Time) (responses.Response, int) {
+	resp := &cachedResponse{body: e.Body}
+	resp.Status = e.Status
+	resp.Header = e.Header.Clone()
+	if resp.Header.Get("Content-Type") == "" {
+		resp.DelHeader("Content-Type")
+	}
+	resp.SetHeader("Age", strconv.Itoa(int(e.age(now).Seconds())))
+	resp.SetHeader("X-Cache", result)
+
+	return withVary(resp, vary), e.Status
+}
+
+// withVary adds the Vary header to responses that can carry headers.
+func withVary(resp responses.Response, vary []string) responses.Response {
+	if len(vary) == 0 {
+		return resp
+	}
+	if h, ok := resp.(responses.Headed); ok {
+		head := h.ResponseHead()
+		if head.Header == nil {
+			head.Header = make(http.Header)
+		}
+		head.Header.Add("Vary", strings.Join(vary, ", "))
+	}
+	return resp
+}
+
+// directives parses the Cache-Control header of r, or of header if r is nil.
+func directives(r *http.Request, header http.Header) map[string]string {
+	if r != nil {
+		header = r.Header
+	}
+	if header == nil {
+		return nil
+	}
+
+	res := make(map[string]string)
+	for _, part := range strings.Split(strings.Join(header.Values("Cache-Control"), ","), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			res[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return res
+}
+
+func seconds(v string, def time.Duration) time.Duration {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return def
+	}
+	return time.Duration(n) * time.Second
+}
+
+type cachedResponse struct {
+	responses.Head
+	body []byte
+}
+
+func (r *cachedResponse) PrepareResponse(w http.ResponseWriter) error {
+	_, err := w.Write(r.body)
+	return err
+}
+
+func (r *cachedResponse) String() string {
+	return string(r.body)
+}
+
+type recorder struct {
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(int) {}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/porebric/resty/requests"
+	"github.com/porebric/resty/responses"
+)
+
+type profileRequest struct{}
+
+func (r *profileRequest) Validate() (bool, string, string) { return true, "", "" }
+func (r *profileRequest) Methods() []string                { return []string{http.MethodGet} }
+func (r *profileRequest) Path() (string, bool)             { return "/me", false }
+func (r *profileRequest) String() string                   { return "" }
+
+// profile answers the token of the caller, with Cache-Control if it is set.
+func profile(cacheControl string) func(context.Context, *profileRequest) (responses.Response, int) {
+	return func(ctx context.Context, _ *profileRequest) (responses.Response, int) {
+		resp := &responses.JSON[string]{Data: requests.HTTPRequest(ctx).Header.Get("Authorization")}
+		if cacheControl != "" {
+			resp.SetHeader("Cache-Control", cacheControl)
+		}
+		return resp, http.StatusOK
+	}
+}
+
+func get(t *testing.T, action func(context.Context, *profileRequest) (responses.Response, int), token string) string {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/me", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, _ := action(requests.WithHTTPRequest(context.Background(), r), new(profileRequest))
+
+	w := httptest.NewRecorder()
+	if err := resp.PrepareResponse(w); err != nil {
+		t.Fatal(err)
+	}
+	return w.Body.String()
+}
+
+func TestCredentialedResponsesAreNotShared(t *testing.T) {
+	action := Action(New(NewLRU(0)), "me", Options[*profileRequest]{}, profile(""))
+
+	alice := get(t, action, "alice")
+	bob := get(t, action, "bob")
+	if alice == bob {
+		t.Fatalf("bob got the response of alice: %s", bob)
+	}
+	if anonymous := get(t, action, ""); anonymous == alice {
+		t.Fatalf("anonymous got the response of alice: %s", anonymous)
+	}
+}
+
+func TestPublicCredentialedResponsesAreCached(t *testing.T) {
+	action := Action(New(NewLRU(0)), "me", Options[*profileRequest]{}, profile("public, max-age=60"))
+
+	first := get(t, action, "alice")
+	if second := get(t, action, "bob"); second != first {
+		t.Fatalf("public response not cached: %s, %s", first, second)
+	}
+}
@@ -0,0 +1,53 @@
+package cache
+
+import "sync"
+
+// group collapses concurrent calls with the same key into one.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	wg    sync.WaitGroup
+	entry *Entry
+	err   error
+}
+
+// do runs fn once for all concurrent callers of key. shared reports whether the result came from another caller.
+func (g *group) do(key string, fn func() (*Entry, error)) (entry *Entry, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.entry, c.err, true
+	}
+
+	c := new(call)
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		c.wg.Done()
+
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+	}()
+
+	c.entry, c.err = fn()
+	return c.entry, c.err, false
+}
+
+// running reports whether a call for key is in flight.
+func (g *group) running(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, ok := g.calls[key]
+	return ok
+}
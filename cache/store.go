@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Entry is a rendered response. Stores may serialize it, all fields are exported.
+type Entry struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"stored_at"`
+	// FreshFor is how long the entry is served without revalidation, StaleFor how long after that it is served
+	// while being refreshed in the background.
+	FreshFor time.Duration `json:"fresh_for"`
+	StaleFor time.Duration `json:"stale_for"`
+}
+
+func (e *Entry) age(now time.Time) time.Duration {
+	return now.Sub(e.StoredAt)
+}
+
+func (e *Entry) fresh(now time.Time) bool {
+	return e.age(now) < e.FreshFor
+}
+
+// ExpiresAt is the moment the entry can no longer be served at all.
+func (e *Entry) ExpiresAt() time.Time {
+	return e.StoredAt.Add(e.FreshFor + e.StaleFor)
+}
+
+func (e *Entry) size() int64 {
+	n := int64(len(e.Body))
+	for k, vv := range e.Header {
+		for _, v := range vv {
+			n += int64(len(k) + len(v))
+		}
+	}
+	return n
+}
+
+// Store keeps the entries, shared caches like Redis implement it. Get returns nil for missing entries.
+type Store interface {
+	Get(ctx context.Context, key string) (*Entry, error)
+	Set(ctx context.Context, key string, e *Entry) error
+	Delete(ctx context.Context, key string) error
+}
+
+// LRU is an in-memory Store bounded by the size of the entries. The least recently used entries are evicted first.
+type LRU struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+type lruItem struct {
+	key   string
+	entry *Entry
+	size  int64
+}
+
+// NewLRU returns an LRU holding up to maxBytes of headers and bodies, 64 MB if maxBytes is not positive.
+func NewLRU(maxBytes int64) *LRU {
+	if maxBytes <= 0 {
+		maxBytes = 64 << 20
+	}
+	return &LRU{maxBytes: maxBytes, items: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *LRU) Get(_ context.Context, key string) (*Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, nil
+	}
+
+	item := el.Value.(*lruItem)
+	if time.Now().After(item.entry.ExpiresAt()) {
+		c.remove(el)
+		return nil, nil
+	}
+
+	c.order.MoveToFront(el)
+	return item.entry, nil
+}
+
+func (c *LRU) Set(_ context.Context, key string, e *Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+
+	item := &lruItem{key: key, entry: e, size: e.size()}
+	if item.size > c.maxBytes {
+		return nil
+	}
+
+	c.items[key] = c.order.PushFront(item)
+	c.size += item.size
+
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *LRU) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	return nil
+}
+
+// Len returns the number of entries.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *LRU) remove(el *list.Element) {
+	item := c.order.Remove(el).(*lruItem)
+	delete(c.items, item.key)
+	c.size -= item.size
+}
@@ -0,0 +1,277 @@
+// Package csrf protects cookie-authenticated routes against cross-site request forgery.
+//
+// Unsafe requests must come from an allowed origin and carry the token in the X-CSRF-Token header or the
+// csrf_token form field. In DoubleSubmit mode the token is the value of a cookie readable by scripts, signed
+// with Key and bound to the session id if there is a session. In Synchronizer mode it is kept in the session.
+// session.Manager must run first in both modes:
+//
+//	protect, err := csrf.New(csrf.Options{Key: csrfKey, AllowedOrigins: router.CorsAllowedOrigins()})
+//	if err != nil {
+//		return err
+//	}
+//	router.MuxRouter().Use(sessions.Handler, protect.Handler)
+//
+// Rejected requests answer errors.ErrorInvalidAccess.
+package csrf
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/porebric/logger"
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/session"
+)
+
+type Mode int
+
+const (
+	DoubleSubmit Mode = iota
+	Synchronizer
+)
+
+var ErrShortKey = stderrors.New("csrf: key must be at least 32 bytes")
+
+type Options struct {
+	Mode Mode
+	// Key signs the DoubleSubmit tokens, so a cookie planted by a sibling domain is not accepted.
+	// It must be at least 32 bytes, Synchronizer does not use it.
+	Key []byte
+	// AllowedOrigins are trusted besides the origin of the request itself. "*" is ignored.
+	AllowedOrigins []string
+	// CookieName is the DoubleSubmit cookie and the session key of Synchronizer, "csrf_token" by default.
+	CookieName string
+	// HeaderName carries the token, "X-CSRF-Token" by default. FormField is checked for form posts, "csrf_token".
+	HeaderName string
+	FormField  string
+	// Insecure allows the cookie over plain HTTP, for local development.
+	Insecure bool
+	// ExemptPaths are path prefixes not checked, like webhook receivers.
+	ExemptPaths []string
+	// ExemptRoutes are routes not checked, by their RouteSpec.Name.
+	ExemptRoutes []string
+	// Exempt skips the check for matching requests. Requests with X-API-Key or a Bearer Authorization header
+	// are exempt too, browsers don't send those by themselves, unless they also carry the CSRF cookie or
+	// a session, which a forged request would ride on.
+	Exempt func(r *http.Request) bool
+}
+
+type Protector struct {
+	opts Options
+}
+
+// New returns ErrShortKey if DoubleSubmit has no Key of at least 32 bytes.
+func New(opts Options) (*Protector, error) {
+	if opts.Mode == DoubleSubmit && len(opts.Key) < 32 {
+		return nil, ErrShortKey
+	}
+	if opts.CookieName == "" {
+		opts.CookieName = "csrf_token"
+	}
+	if opts.HeaderName == "" {
+		opts.HeaderName = "X-CSRF-Token"
+	}
+	if opts.FormField == "" {
+		opts.FormField = "csrf_token"
+	}
+	return &Protector{opts: opts}, nil
+}
+
+type ctxKey struct{}
+
+// Token returns the token of the request, to be rendered into forms or returned to scripts.
+func Token(ctx context.Context) string {
+	t, _ := ctx.Value(ctxKey{}).(string)
+	return t
+}
+
+// Handler checks unsafe requests and makes sure every request has a token. It is a mux.MiddlewareFunc.
+func (p *Protector) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.exempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := p.token(w, r)
+		if !ok {
+			reject(w, r, "csrf session missing")
+			return
+		}
+
+		if !safeMethod(r.Method) {
+			if !p.originAllowed(r) {
+				reject(w, r, "csrf origin not allowed")
+				return
+			}
+
+			sent := r.Header.Get(p.opts.HeaderName)
+			if sent == "" && isForm(r) {
+				sent = r.PostFormValue(p.opts.FormField)
+			}
+			if sent == "" {
+				reject(w, r, "csrf token missing")
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				reject(w, r, "csrf token mismatch")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, token)))
+	})
+}
+
+// token returns the current token, creating it if needed. It reports false if Synchronizer has no session.
+func (p *Protector) token(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if p.opts.Mode == Synchronizer {
+		s := session.FromContext(r.Context())
+		if s == nil {
+			return "", false
+		}
+
+		var token string
+		if !s.Get(p.opts.CookieName, &token) || token == "" {
+			token = newToken()
+			if err := s.Set(p.opts.CookieName, token); err != nil {
+				return "", false
+			}
+		}
+		return token, true
+	}
+
+	// a token of another session, like the one before login, is replaced
+	sessionID := ""
+	if s := session.FromContext(r.Context()); s != nil && !s.IsNew() {
+		sessionID = s.ID()
+	}
+	if c, err := r.Cookie(p.opts.CookieName); err == nil && p.valid(c.Value, sessionID) {
+		return c.Value, true
+	}
+
+	token := p.signedToken(sessionID)
+	http.SetCookie(w, &http.Cookie{
+		Name:     p.opts.CookieName,
+		Value:    token,
+		Path:     "/",
+		Secure:   !p.opts.Insecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token, true
+}
+
+// signedToken returns "<random>.<mac>", the HMAC of Key over the session id and the random part.
+func (p *Protector) signedToken(sessionID string) string {
+	random := newToken()
+	return random + "." + p.mac(sessionID, random)
+}
+
+func (p *Protector) valid(token, sessionID string) bool {
+	random, mac, ok := strings.Cut(token, ".")
+	return ok && hmac.Equal([]byte(mac), []byte(p.mac(sessionID, random)))
+}
+
+func (p *Protector) mac(sessionID, random string) string {
+	mac := hmac.New(sha256.New, p.opts.Key)
+	mac.Write([]byte(sessionID + "." + random))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (p *Protector) exempt(r *http.Request) bool {
+	if p.apiClient(r) {
+		return true
+	}
+
+	for _, prefix := range p.opts.ExemptPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	if route := mux.CurrentRoute(r); route != nil && slices.Contains(p.opts.ExemptRoutes, route.GetName()) {
+		return true
+	}
+	return p.opts.Exempt != nil && p.opts.Exempt(r)
+}
+
+// apiClient reports whether r authenticates with X-API-Key or a Bearer token and carries no cookie credentials.
+func (p *Protector) apiClient(r *http.Request) bool {
+	scheme, _, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if r.Header.Get("X-API-Key") == "" && !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+
+	if _, err := r.Cookie(p.opts.CookieName); err == nil {
+		return false
+	}
+	s := session.FromContext(r.Context())
+	return s == nil || s.IsNew()
+}
+
+// originAllowed checks Origin, or Referer if there is no Origin. Requests with neither rely on the token.
+func (p *Protector) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		ref, err := url.Parse(r.Referer())
+		if r.Referer() == "" {
+			return origin == ""
+		}
+		if err != nil || ref.Host == "" {
+			return false
+		}
+		origin = ref.Scheme + "://" + ref.Host
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	return slices.ContainsFunc(p.opts.AllowedOrigins, func(allowed string) bool {
+		return allowed != "*" && strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin)
+	})
+}
+
+func reject(w http.ResponseWriter, r *http.Request, msg string) {
+	logger.Warn(r.Context(), "csrf rejected", "reason", msg, "method", r.Method, "path", r.URL.Path)
+
+	resp, httpCode := errors.GetCustomError(msg, errors.ErrorInvalidAccess)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpCode)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+func isForm(r *http.Request) bool {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mt == "application/x-www-form-urlencoded"
+}
+
+func newToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,134 @@
+package csrf
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/session"
+)
+
+var key = bytes.Repeat([]byte("k"), 32)
+
+// newTestRouter returns a router answering the token on /form and 200 on POST /submit and the "hook" route.
+func newTestRouter(t *testing.T, opts Options) *mux.Router {
+	t.Helper()
+
+	errors.Init(nil)
+	p, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := session.New(session.Options{Store: session.NewServerStore(session.NewMemoryBackend()), Insecure: true})
+
+	r := mux.NewRouter()
+	r.Use(sessions.Handler, p.Handler)
+	r.HandleFunc("/form", func(w http.ResponseWriter, r *http.Request) {
+		_ = session.FromContext(r.Context()).Set("user_id", 1)
+		_, _ = w.Write([]byte(Token(r.Context())))
+	}).Methods(http.MethodGet)
+	r.HandleFunc("/submit", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodPost)
+	r.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodPost).Name("hook")
+	return r
+}
+
+type client struct {
+	r       *mux.Router
+	cookies map[string]*http.Cookie
+}
+
+func (c *client) do(method, path string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "http://api.example"+path, nil)
+	for _, cookie := range c.cookies {
+		req.AddCookie(cookie)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	c.r.ServeHTTP(w, req)
+	for _, cookie := range w.Result().Cookies() {
+		c.cookies[cookie.Name] = cookie
+	}
+	return w
+}
+
+func TestDoubleSubmit(t *testing.T) {
+	c := &client{r: newTestRouter(t, Options{Key: key}), cookies: make(map[string]*http.Cookie)}
+
+	token := c.do(http.MethodGet, "/form").Body.String()
+	if w := c.do(http.MethodPost, "/submit"); w.Code != http.StatusForbidden {
+		t.Fatalf("without token: status = %d", w.Code)
+	}
+
+	// the session was created by the first request, the token bound to no session is replaced
+	token = c.do(http.MethodGet, "/form").Body.String()
+	if w := c.do(http.MethodPost, "/submit", "X-CSRF-Token", token); w.Code != http.StatusOK {
+		t.Fatalf("with token: status = %d, body %s", w.Code, w.Body.String())
+	}
+	if w := c.do(http.MethodPost, "/submit", "X-CSRF-Token", token, "Origin", "https://evil.example"); w.Code != http.StatusForbidden {
+		t.Fatalf("foreign origin: status = %d", w.Code)
+	}
+}
+
+func TestDoubleSubmitRejectsUnsignedCookie(t *testing.T) {
+	c := &client{r: newTestRouter(t, Options{Key: key}), cookies: make(map[string]*http.Cookie)}
+	c.cookies["csrf_token"] = &http.Cookie{Name: "csrf_token", Value: "planted"}
+
+	if w := c.do(http.MethodPost, "/submit", "X-CSRF-Token", "planted"); w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+}
+
+func TestDoubleSubmitTokenBoundToSession(t *testing.T) {
+	r := newTestRouter(t, Options{Key: key})
+	alice := &client{r: r, cookies: make(map[string]*http.Cookie)}
+	mallory := &client{r: r, cookies: make(map[string]*http.Cookie)}
+
+	alice.do(http.MethodGet, "/form")
+	alice.do(http.MethodGet, "/form")
+	mallory.do(http.MethodGet, "/form")
+	token := mallory.do(http.MethodGet, "/form").Body.String()
+
+	// mallory's cookie and token planted next to alice's session
+	alice.cookies["csrf_token"] = mallory.cookies["csrf_token"]
+	if w := alice.do(http.MethodPost, "/submit", "X-CSRF-Token", token); w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+}
+
+func TestExemption(t *testing.T) {
+	for _, mode := range []Mode{DoubleSubmit, Synchronizer} {
+		r := newTestRouter(t, Options{Mode: mode, Key: key, ExemptRoutes: []string{"hook"}})
+
+		api := &client{r: r, cookies: make(map[string]*http.Cookie)}
+		if w := api.do(http.MethodPost, "/submit", "Authorization", "Bearer abc"); w.Code != http.StatusOK {
+			t.Fatalf("mode %d: bearer without cookies: status = %d", mode, w.Code)
+		}
+		if w := api.do(http.MethodPost, "/submit", "X-API-Key", "abc"); w.Code != http.StatusOK {
+			t.Fatalf("mode %d: api key without cookies: status = %d", mode, w.Code)
+		}
+
+		browser := &client{r: r, cookies: make(map[string]*http.Cookie)}
+		browser.do(http.MethodGet, "/form")
+		if w := browser.do(http.MethodPost, "/submit", "Authorization", "Bearer abc"); w.Code != http.StatusForbidden {
+			t.Fatalf("mode %d: bearer with cookies: status = %d, want 403", mode, w.Code)
+		}
+		if w := browser.do(http.MethodPost, "/hook"); w.Code != http.StatusOK {
+			t.Fatalf("mode %d: exempt route: status = %d", mode, w.Code)
+		}
+	}
+}
+
+func TestNewRejectsShortKey(t *testing.T) {
+	if _, err := New(Options{Key: []byte("short")}); err != ErrShortKey {
+		t.Fatalf("err = %v, want ErrShortKey", err)
+	}
+	if _, err := New(Options{Mode: Synchronizer}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,8 +1,10 @@
 package errors
 
 import (
-	"github.com/porebric/resty/responses"
+	stderrors "errors"
 	"net/http"
+
+	"github.com/porebric/resty/responses"
 )
 
 const ErrorNoError = -1
@@ -21,6 +23,36 @@ const ErrorCritical = 10           // ErrorCritical Some error in code
 
 const ErrorNotFound = 11 // ErrorNotFound Object not found in db
 
+const ErrorRequestTooLarge = 12      // ErrorRequestTooLarge Request body or uploaded file exceeds the limit
+const ErrorUnsupportedMediaType = 13 // ErrorUnsupportedMediaType Content type of the request or file is not allowed
+const ErrorPreconditionFailed = 14   // ErrorPreconditionFailed If-Match does not match the current resource
+const ErrorConflict = 15             // ErrorConflict Request conflicts with the current state, like a failed patch test
+const ErrorServiceUnavailable = 16   // ErrorServiceUnavailable Server cannot take the request now, like a full job queue
+const ErrorTooManyRequests = 17      // ErrorTooManyRequests Client exceeded its rate limit
+const ErrorUnableDeleteData = 18     // ErrorUnableDeleteData Unable to delete data from the table
+
+var (
+	// ErrNotFound is returned by stores when the requested object does not exist. It maps to ErrorNotFound.
+	ErrNotFound = stderrors.New("not found")
+	// ErrPreconditionFailed is returned by stores when a conditional write does not match. It maps to ErrorPreconditionFailed.
+	ErrPreconditionFailed = stderrors.New("precondition failed")
+)
+
+// Error is returned from request initialization to answer with a specific code and message
+// instead of ErrorInvalidRequest.
+type Error struct {
+	Code    int32
+	Message string
+}
+
+func New(code int32, msg string) *Error {
+	return &Error{Code: code, Message: msg}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
 type CustomError struct {
 	HttpCode    int    `json:"httpCode"`
 	Message     string `json:"message"`
@@ -43,6 +75,13 @@ func Init(additionalErrorsMap map[int32]CustomError) {
 	CustomErrorMap[ErrorUserUnauthorized] = CustomError{http.StatusUnauthorized, "user is unauthorized", "User has not an auth token"}
 	CustomErrorMap[ErrorCritical] = CustomError{http.StatusInternalServerError, "critical error", "Some error in code"}
 	CustomErrorMap[ErrorNotFound] = CustomError{http.StatusNotFound, "not found", "Something not found"}
+	CustomErrorMap[ErrorRequestTooLarge] = CustomError{http.StatusRequestEntityTooLarge, "request too large", "Request body or uploaded file exceeds the limit"}
+	CustomErrorMap[ErrorUnsupportedMediaType] = CustomError{http.StatusUnsupportedMediaType, "unsupported media type", "Content type of the request or file is not allowed"}
+	CustomErrorMap[ErrorPreconditionFailed] = CustomError{http.StatusPreconditionFailed, "precondition failed", "If-Match does not match the current resource"}
+	CustomErrorMap[ErrorConflict] = CustomError{http.StatusConflict, "conflict", "Request conflicts with the current state"}
+	CustomErrorMap[ErrorServiceUnavailable] = CustomError{http.StatusServiceUnavailable, "service unavailable", "Server cannot take the request now"}
+	CustomErrorMap[ErrorTooManyRequests] = CustomError{http.StatusTooManyRequests, "too many requests", "Client exceeded its rate limit"}
+	CustomErrorMap[ErrorUnableDeleteData] = CustomError{http.StatusInternalServerError, "internal error", "Unable to delete data from the table"}
 
 	for k, v := range additionalErrorsMap {
 		CustomErrorMap[k] = v
@@ -2,16 +2,19 @@ package resty
 
 import (
 	"context"
-	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
+	"slices"
 
 	"github.com/porebric/logger"
 	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/internal/cleanup"
 	"github.com/porebric/resty/middleware"
 	"github.com/porebric/resty/requests"
 	"github.com/porebric/resty/responses"
+	"github.com/porebric/resty/validation"
 	"github.com/porebric/tracer"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -24,92 +27,115 @@ var (
 		},
 		[]string{"path", "code"},
 	)
+	responseSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "The size of HTTP response bodies, tracked by path and response code.",
+			Buckets: prometheus.ExponentialBuckets(64, 4, 10),
+		},
+		[]string{"path", "code"},
+	)
 )
 
 func serveHTTP[R requests.Request](
+	spec RouteSpec,
 	action func(context.Context, R) (responses.Response, int),
 	logFn func() *logger.Logger,
 	initRequest func(ctx context.Context, r *http.Request) (context.Context, R, error),
 	mm ...func() middleware.Middleware,
 ) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		defer getDeferCatchPanic(logFn(), w)
-
 		var (
-			err      error
-			httpCode int
-			resp     responses.Response
+			err  error
+			resp responses.Response
+			req  = newRequest[R]()
+			rw   = newResponseWriter(w)
+			ctx  = r.Context()
+			path = spec.Path
 		)
 
-		var req R
-
-		path, showPath := req.Path()
 		logPath := path
-		if showPath {
+		if spec.ShowPath {
 			logPath = r.URL.Path
 		}
 
-		defer func() {
-			requestCounter.WithLabelValues(fmt.Sprintf("%s:%s", r.Method, path), fmt.Sprintf("%d", httpCode)).Inc()
-		}()
-
-		ctx, span := tracer.StartSpan(r.Context(), fmt.Sprintf("%s:%s", r.Method, path))
+		ctx, span := tracer.StartSpan(ctx, fmt.Sprintf("%s:%s", r.Method, path))
 		defer span.End()
 
 		ctx = logger.ToContext(ctx, logFn().With("token", span.TraceId()))
+		ctx = cleanup.With(ctx)
+		ctx = requests.WithHTTPRequest(ctx, r)
+		defer func() {
+			cleanup.SetStatus(ctx, rw.Status())
+			if err := cleanup.Run(ctx); err != nil {
+				logger.Warn(ctx, "cleanup", "error", err)
+			}
+		}()
 
-		w.Header().Set("Content-Type", "application/json")
-
-		ok := false
-		for _, method := range req.Methods() {
-			if method == r.Method {
-				ok = true
+		defer func() {
+			if err := rw.finish(); err != nil {
+				logger.Warn(ctx, "write response", "error", err)
 			}
-		}
 
-		if !ok {
-			httpCode = http.StatusMethodNotAllowed
-			resp = &responses.ErrorResponse{Message: "unknown method"}
+			code := fmt.Sprintf("%d", rw.Status())
+			requestCounter.WithLabelValues(fmt.Sprintf("%s:%s", r.Method, path), code).Inc()
+			responseSize.WithLabelValues(fmt.Sprintf("%s:%s", r.Method, path), code).Observe(float64(rw.Size()))
 
-			w.WriteHeader(httpCode)
-			_ = json.NewEncoder(w).Encode(resp)
+			span.Tag("http.status_code", rw.Status())
+			span.Tag("http.response_size", rw.Size())
 
-			logger.Info(ctx, "http request", "content", req.String(), "method", r.Method, "path", logPath, "response", resp.String())
-			return
+			logger.Info(ctx, "http request", "content", safeString(req), "method", r.Method, "path", logPath, "code", rw.Status(), "size", rw.Size(), "response", safeString(resp))
+		}()
+		defer getDeferCatchPanic(ctx, rw)
+
+		rw.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodHead {
+			rw.discardBody()
 		}
 
+		var httpCode int
 		if ctx, req, err = initRequest(ctx, r); err != nil {
-			resp, httpCode = errors.GetCustomError("", errors.ErrorInvalidRequest)
-			w.WriteHeader(httpCode)
-			_ = json.NewEncoder(w).Encode(resp)
-			logger.Info(ctx, "http request", "content", req.String(), "method", r.Method, "path", logPath, "response", resp.String())
+			var reqErr *errors.Error
+			if stderrors.As(err, &reqErr) {
+				resp, httpCode = errors.GetCustomError(reqErr.Message, reqErr.Code)
+			} else {
+				resp, httpCode = errors.GetCustomError("", errors.ErrorInvalidRequest)
+			}
+			writeResponse(ctx, rw, r, httpCode, resp)
 			return
 		}
 
-		ctx, resp, httpCode = checkAction(ctx, req, w, mm...)
+		ctx, resp, httpCode = checkAction(ctx, req, mm...)
 		if httpCode != 0 {
-			w.WriteHeader(httpCode)
-			_ = json.NewEncoder(w).Encode(resp)
-			logger.Info(ctx, "http request", "content", req.String(), "method", r.Method, "path", logPath, "response", resp.String())
+			writeResponse(ctx, rw, r, httpCode, resp)
 			return
 		}
 
 		resp, httpCode = action(ctx, req)
+		writeResponse(ctx, rw, r, httpCode, resp)
+	}
+}
 
-		w.WriteHeader(httpCode)
+func Endpoint[R requests.Request](r Router, req func(ctx context.Context, r *http.Request) (context.Context, R, error), action func(context.Context, R) (responses.Response, int), mm ...func() middleware.Middleware) {
+	EndpointWithSpec(r, RouteSpec{}, req, action, mm...)
+}
 
-		if err = resp.PrepareResponse(w); err != nil {
-			w.WriteHeader(http.StatusExpectationFailed)
-			_, _ = w.Write([]byte{})
-		}
+// EndpointWithSpec registers an endpoint whose path and methods are taken from spec instead of the zero value of R.
+// The same request type can therefore be served on several paths.
+func EndpointWithSpec[R requests.Request](r Router, spec RouteSpec, req func(ctx context.Context, r *http.Request) (context.Context, R, error), action func(context.Context, R) (responses.Response, int), mm ...func() middleware.Middleware) {
+	spec = resolveSpec[R](spec)
+	if err := validation.Compile(newRequest[R]()); err != nil {
+		panic(fmt.Sprintf("resty: route %s: %v", spec.Path, err))
+	}
 
-		logger.Info(ctx, "http request", "content", req.String(), "method", r.Method, "path", logPath, "response", resp.String())
-		return
+	e := &endpoint{
+		spec:           spec,
+		request:        typeName(newRequest[R]()),
+		middlewares:    middlewareNames(mm),
+		policies:       endpointPolicies(mm),
+		checksPolicies: true,
 	}
-}
 
-func Endpoint[R requests.Request](r Router, req func(ctx context.Context, r *http.Request) (context.Context, R, error), action func(context.Context, R) (responses.Response, int), mm ...func() middleware.Middleware) {
-	var exampleReq R
-	path, _ := exampleReq.Path()
-	r.MuxRouter().HandleFunc(path, serveHTTP(action, r.LogFn, req, mm...)).Methods(exampleReq.Methods()...)
+	mm = append(slices.Clone(mm), routeAuthorization(r, spec.Group, len(e.policies) != 0))
+	handle(r, e, serveHTTP(spec, action, r.LogFn, req, mm...))
 }
@@ -0,0 +1,86 @@
+// Package cleanup collects functions that release per-request resources once the response is written.
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+type ctxKey struct{}
+
+type list struct {
+	mu     sync.Mutex
+	fns    []func(status int) error
+	status int
+}
+
+// With returns a context that collects cleanup functions. A context that already collects them is returned as is.
+func With(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(ctxKey{}).(*list); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxKey{}, new(list))
+}
+
+// Add schedules fn for ctx. It reports false if ctx was not prepared with With.
+func Add(ctx context.Context, fn func() error) bool {
+	return OnStatus(ctx, func(int) error { return fn() })
+}
+
+// OnStatus schedules fn for ctx like Add, fn gets the status set with SetStatus, 0 if it was not set.
+func OnStatus(ctx context.Context, fn func(status int) error) bool {
+	l, ok := ctx.Value(ctxKey{}).(*list)
+	if !ok {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.fns = append(l.fns, fn)
+	return true
+}
+
+// Detach moves the functions scheduled for ctx to a new list and returns a context collecting into it,
+// so the resources outlive the request and are released by Run on the returned context.
+func Detach(ctx context.Context) context.Context {
+	detached := new(list)
+	if l, ok := ctx.Value(ctxKey{}).(*list); ok {
+		l.mu.Lock()
+		detached.fns, l.fns = l.fns, nil
+		l.mu.Unlock()
+	}
+	return context.WithValue(ctx, ctxKey{}, detached)
+}
+
+// SetStatus records the status of the response, or of the job for a detached context, before Run.
+func SetStatus(ctx context.Context, status int) {
+	if l, ok := ctx.Value(ctxKey{}).(*list); ok {
+		l.mu.Lock()
+		l.status = status
+		l.mu.Unlock()
+	}
+}
+
+// Run calls the scheduled functions in reverse order and joins their errors.
+func Run(ctx context.Context) error {
+	l, ok := ctx.Value(ctxKey{}).(*list)
+	if !ok {
+		return nil
+	}
+
+	l.mu.Lock()
+	fns, status := l.fns, l.status
+	l.fns = nil
+	l.mu.Unlock()
+
+	var errs []error
+	for i := len(fns) - 1; i >= 0; i-- {
+		if err := fns[i](status); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
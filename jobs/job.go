@@ -0,0 +1,101 @@
+// Package jobs runs long operations on a bounded worker pool and keeps their state in a Store until they expire.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/porebric/resty/errors"
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+type Job struct {
+	ID       string  `json:"id"`
+	Status   Status  `json:"status"`
+	Progress float64 `json:"progress"`
+	// Code is the status code the task answered with.
+	Code   int             `json:"code,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Done reports whether the job has finished, successfully or not.
+func (j *Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+// Store keeps the jobs. Get returns errors.ErrNotFound for unknown and expired jobs.
+type Store interface {
+	Save(ctx context.Context, job *Job) error
+	Get(ctx context.Context, id string) (*Job, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore is an in-process Store. Expired jobs are dropped on access and on every save.
+type MemoryStore struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]*Job)}
+}
+
+func (s *MemoryStore) Save(_ context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, j := range s.jobs {
+		if expired(j, now) {
+			delete(s.jobs, id)
+		}
+	}
+
+	cp := *job
+	s.jobs[job.ID] = &cp
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+	if expired(j, time.Now()) {
+		delete(s.jobs, id)
+		return nil, errors.ErrNotFound
+	}
+
+	cp := *j
+	return &cp, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.jobs, id)
+	return nil
+}
+
+func expired(j *Job, now time.Time) bool {
+	return j.ExpiresAt != nil && now.After(*j.ExpiresAt)
+}
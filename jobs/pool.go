@@ -0,0 +1,245 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/porebric/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	jobsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jobs_total",
+			Help: "The number of finished jobs, tracked by status.",
+		},
+		[]string{"status"},
+	)
+	jobsQueued = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "jobs_queued",
+			Help: "The number of jobs waiting for a worker.",
+		},
+	)
+)
+
+var (
+	ErrQueueFull = stderrors.New("jobs: queue is full")
+	ErrClosed    = stderrors.New("jobs: pool is closed")
+)
+
+// Task is the work of a job. code and body are stored as the result, or as the error if code is 400 or more.
+type Task func(ctx context.Context) (code int, body json.RawMessage, err error)
+
+type Options struct {
+	// Workers is the number of tasks executed at the same time, 4 by default.
+	Workers int
+	// QueueSize limits the tasks waiting for a worker, 100 by default.
+	QueueSize int
+	// TTL is how long finished jobs are kept, 1 hour by default.
+	TTL time.Duration
+	// Store keeps the jobs, a MemoryStore by default.
+	Store Store
+}
+
+type Pool struct {
+	opts  Options
+	queue chan queued
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type queued struct {
+	ctx  context.Context
+	job  *Job
+	task Task
+}
+
+func NewPool(opts Options) *Pool {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = time.Hour
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore()
+	}
+
+	p := &Pool{opts: opts, queue: make(chan queued, opts.QueueSize)}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	for i := 0; i < opts.Workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+
+	return p
+}
+
+func (p *Pool) Store() Store {
+	return p.opts.Store
+}
+
+// Submit stores a pending job and enqueues task. The task gets ctx without its cancellation, so values
+// like the logger survive the request. The returned job is a copy, the worker keeps updating its own.
+func (p *Pool) Submit(ctx context.Context, task Task) (*Job, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return nil, ErrClosed
+	}
+
+	now := time.Now()
+	job := &Job{ID: newID(), Status: StatusPending, CreatedAt: now, UpdatedAt: now}
+	if err := p.opts.Store.Save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	snapshot := *job
+
+	select {
+	case p.queue <- queued{ctx: context.WithoutCancel(ctx), job: job, task: task}:
+		jobsQueued.Inc()
+		return &snapshot, nil
+	default:
+		_ = p.opts.Store.Delete(ctx, job.ID)
+		return nil, ErrQueueFull
+	}
+}
+
+// Close stops accepting jobs and waits for the queued ones. If ctx is done first the running tasks are cancelled.
+func (p *Pool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return fmt.Errorf("jobs: drain: %w", ctx.Err())
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for q := range p.queue {
+		jobsQueued.Dec()
+		p.run(q)
+	}
+}
+
+func (p *Pool) run(q queued) {
+	ctx, cancel := context.WithCancel(q.ctx)
+	defer cancel()
+	stop := context.AfterFunc(p.ctx, cancel)
+	defer stop()
+
+	job := q.job
+	ctx = context.WithValue(ctx, progressKey{}, &progress{pool: p, id: job.ID})
+
+	job.Status, job.UpdatedAt = StatusRunning, time.Now()
+	p.save(ctx, job)
+
+	code, body, err := p.execute(ctx, q.task)
+
+	now := time.Now()
+	expires := now.Add(p.opts.TTL)
+	if current, getErr := p.opts.Store.Get(ctx, job.ID); getErr == nil {
+		job.Progress = current.Progress
+	}
+	job.Code, job.UpdatedAt, job.ExpiresAt = code, now, &expires
+
+	switch {
+	case err != nil:
+		job.Status = StatusFailed
+		job.Error, _ = json.Marshal(map[string]string{"message": err.Error()})
+	case code >= 400:
+		job.Status = StatusFailed
+		job.Error = body
+	default:
+		job.Status, job.Progress = StatusSucceeded, 1
+		job.Result = body
+	}
+
+	jobsTotal.WithLabelValues(string(job.Status)).Inc()
+	p.save(ctx, job)
+	logger.Info(ctx, "job finished", "id", job.ID, "status", job.Status, "code", code)
+}
+
+func (p *Pool) execute(ctx context.Context, task Task) (code int, body json.RawMessage, err error) {
+	defer func() {
+		if rec := recover(); rec != any(nil) {
+			logger.Error(ctx, fmt.Errorf("error: %v", rec), "critical error", "stacktrace", string(debug.Stack()))
+			code, body, err = 500, nil, stderrors.New("critical error")
+		}
+	}()
+
+	return task(ctx)
+}
+
+func (p *Pool) save(ctx context.Context, job *Job) {
+	if err := p.opts.Store.Save(ctx, job); err != nil {
+		logger.Error(ctx, err, "save job", "id", job.ID)
+	}
+}
+
+type progressKey struct{}
+
+type progress struct {
+	pool *Pool
+	id   string
+}
+
+// SetProgress stores the progress, from 0 to 1, of the job running with ctx. Outside of a job it does nothing.
+func SetProgress(ctx context.Context, value float64) {
+	pr, ok := ctx.Value(progressKey{}).(*progress)
+	if !ok {
+		return
+	}
+
+	job, err := pr.pool.opts.Store.Get(ctx, pr.id)
+	if err != nil {
+		return
+	}
+
+	job.Progress, job.UpdatedAt = min(max(value, 0), 1), time.Now()
+	pr.pool.save(ctx, job)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSubmitReturnsSnapshot(t *testing.T) {
+	p := NewPool(Options{Workers: 1})
+	defer p.Close(context.Background())
+
+	release := make(chan struct{})
+	job, err := p.Submit(context.Background(), func(ctx context.Context) (int, json.RawMessage, error) {
+		for i := 0; i <= 100; i++ {
+			SetProgress(ctx, float64(i)/100)
+		}
+		<-release
+		return 200, json.RawMessage(`"done"`), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// encoding the returned job while the worker runs must not race with it
+	deadline := time.Now().Add(50 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		if _, err := json.Marshal(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+
+	if job.Status != StatusPending {
+		t.Fatalf("snapshot status = %s, want %s", job.Status, StatusPending)
+	}
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := p.Store().Get(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != StatusSucceeded || string(stored.Result) != `"done"` {
+		t.Fatalf("stored job = %+v", stored)
+	}
+}
+
+func TestSubmitAfterClose(t *testing.T) {
+	p := NewPool(Options{})
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.Submit(context.Background(), func(context.Context) (int, json.RawMessage, error) {
+		return 200, nil, nil
+	}); err != ErrClosed {
+		t.Fatalf("err = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,177 @@
+// Package apikey authenticates partner requests with static API keys looked up in a KeyStore.
+//
+// The key is read from a header, X-API-Key by default, or a query parameter. Unknown, malformed and expired
+// keys fail with errors.ErrorUserUnauthorized, keys over the rate of their tier with errors.ErrorTooManyRequests.
+// The key is put into the context and, as an authz.Principal whose scopes are also its permissions,
+// checked by authz policies.
+package apikey
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/porebric/logger"
+	"github.com/porebric/resty/authz"
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/middleware"
+	"github.com/porebric/resty/requests"
+)
+
+// Tier limits the requests of every key in it to Rate per second with bursts of Burst.
+type Tier struct {
+	Rate  float64
+	Burst int
+}
+
+type Config struct {
+	Store KeyStore
+	// Header carrying the key, X-API-Key by default.
+	Header string
+	// Query parameter carrying the key, disabled if empty.
+	Query string
+	// Prefixes lists the accepted key prefixes, any by default.
+	Prefixes []string
+	// Tiers maps Key.Tier to its limit. Keys of unknown tiers are not limited.
+	Tiers    map[string]Tier
+	Optional bool
+}
+
+// New returns a middleware factory authenticating requests with cfg. The factories share the rate limits.
+func New(cfg Config) func() middleware.Middleware {
+	if cfg.Header == "" {
+		cfg.Header = "X-API-Key"
+	}
+
+	limiter := &limiter{buckets: make(map[string]*bucket)}
+	return func() middleware.Middleware {
+		return &Auth{cfg: cfg, limiter: limiter}
+	}
+}
+
+type Auth struct {
+	next    middleware.Middleware
+	cfg     Config
+	limiter *limiter
+}
+
+func (a *Auth) Execute(ctx context.Context, req requests.Request) (context.Context, int32, string) {
+	plain := a.read(ctx)
+	if plain == "" {
+		if a.cfg.Optional {
+			return a.next.Execute(ctx, req)
+		}
+		return ctx, errors.ErrorUserUnauthorized, ""
+	}
+
+	prefix, id, ok := Parse(plain)
+	if !ok || len(a.cfg.Prefixes) != 0 && !slices.Contains(a.cfg.Prefixes, prefix) {
+		logger.Warn(ctx, "api key rejected", "reason", "malformed")
+		return ctx, errors.ErrorUserUnauthorized, ""
+	}
+
+	key, err := a.cfg.Store.Get(ctx, id)
+	if err != nil || key.Prefix != prefix || !key.Matches(plain) {
+		logger.Warn(ctx, "api key rejected", "reason", "unknown", "id", id)
+		return ctx, errors.ErrorUserUnauthorized, ""
+	}
+
+	now := time.Now()
+	if key.Expired(now) {
+		logger.Warn(ctx, "api key rejected", "reason", "expired", "id", id)
+		return ctx, errors.ErrorUserUnauthorized, ""
+	}
+
+	if tier, ok := a.cfg.Tiers[key.Tier]; ok && !a.limiter.allow(key.ID, tier, now) {
+		return ctx, errors.ErrorTooManyRequests, ""
+	}
+
+	if err := a.cfg.Store.Touch(ctx, key.ID, now); err != nil {
+		logger.Warn(ctx, "api key touch", "id", id, "error", err)
+	}
+
+	ctx = context.WithValue(ctx, ctxKey{}, key)
+	ctx = authz.ToContext(ctx, &principal{key: key})
+	return a.next.Execute(ctx, req)
+}
+
+func (a *Auth) SetNext(next middleware.Middleware) {
+	a.next = next
+}
+
+func (a *Auth) read(ctx context.Context) string {
+	r := requests.HTTPRequest(ctx)
+	if r == nil {
+		return ""
+	}
+
+	if v := r.Header.Get(a.cfg.Header); v != "" {
+		return v
+	}
+	if a.cfg.Query != "" {
+		return r.URL.Query().Get(a.cfg.Query)
+	}
+	return ""
+}
+
+type ctxKey struct{}
+
+// FromContext returns the key that authenticated the request.
+func FromContext(ctx context.Context) (*Key, bool) {
+	k, ok := ctx.Value(ctxKey{}).(*Key)
+	return k, ok
+}
+
+type principal struct {
+	key *Key
+}
+
+func (p *principal) ID() string {
+	return p.key.Prefix + "_" + p.key.ID
+}
+
+func (p *principal) HasRole(string) bool {
+	return false
+}
+
+func (p *principal) HasScope(scope string) bool {
+	return p.key.HasScope(scope)
+}
+
+func (p *principal) HasPermission(permission string) bool {
+	return p.key.HasScope(permission)
+}
+
+// limiter keeps a token bucket per key.
+type limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (l *limiter) allow(id string, tier Tier, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	burst := float64(max(tier.Burst, 1))
+
+	b, ok := l.buckets[id]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[id] = b
+	}
+
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*tier.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
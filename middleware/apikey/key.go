@@ -0,0 +1,68 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Key is a stored API key. Only the hash of the plain key is kept.
+// Plain keys look like "<prefix>_<id>_<secret>", the prefix names the kind of key and the id finds it in the store.
+type Key struct {
+	ID         string     `json:"id"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"hash"`
+	Name       string     `json:"name,omitempty"`
+	Scopes     []string   `json:"scopes,omitempty"`
+	Tier       string     `json:"tier,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Generate returns a new plain key and its Key, prefix must not contain underscores.
+// The plain key is shown to the owner once and never stored.
+func Generate(prefix string) (string, *Key) {
+	id, secret := randomHex(4), randomHex(24)
+	plain := prefix + "_" + id + "_" + secret
+
+	return plain, &Key{ID: id, Prefix: prefix, Hash: Hash(plain), CreatedAt: time.Now()}
+}
+
+// Hash returns the hex SHA-256 of a plain key. Keys are random, so a fast hash is enough.
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// Parse splits a plain key into its prefix and id.
+func Parse(plain string) (prefix, id string, ok bool) {
+	parts := strings.Split(plain, "_")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// Matches compares plain with the stored hash in constant time.
+func (k *Key) Matches(plain string) bool {
+	return subtle.ConstantTimeCompare([]byte(Hash(plain)), []byte(k.Hash)) == 1
+}
+
+func (k *Key) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+func (k *Key) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,179 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/porebric/resty/errors"
+)
+
+// KeyStore looks keys up by id. Get returns errors.ErrNotFound for unknown ids.
+// Touch records the last use of a key, stores may persist it lazily.
+type KeyStore interface {
+	Get(ctx context.Context, id string) (*Key, error)
+	Touch(ctx context.Context, id string, at time.Time) error
+}
+
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]*Key
+}
+
+func NewMemoryStore(keys ...*Key) *MemoryStore {
+	s := &MemoryStore{keys: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		s.keys[k.ID] = k
+	}
+	return s
+}
+
+func (s *MemoryStore) Add(_ context.Context, k *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[k.ID] = k
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, id)
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+
+	cp := *k
+	return &cp, nil
+}
+
+func (s *MemoryStore) Touch(_ context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if k, ok := s.keys[id]; ok {
+		k.LastUsedAt = &at
+	}
+	return nil
+}
+
+// FileStore keeps the keys as a JSON array in a file, for small deployments. Last use is written
+// at most once per TouchInterval and key.
+type FileStore struct {
+	*MemoryStore
+
+	path          string
+	TouchInterval time.Duration
+	mu            sync.Mutex
+	// persisted is the last use of every key as written to the file.
+	persisted map[string]time.Time
+}
+
+// NewFileStore loads the keys of path. A missing file is an empty store.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path, TouchInterval: time.Minute, persisted: make(map[string]time.Time)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		s.keys[k.ID] = k
+		if k.LastUsedAt != nil {
+			s.persisted[k.ID] = *k.LastUsedAt
+		}
+	}
+	return s, nil
+}
+
+func (s *FileStore) Add(ctx context.Context, k *Key) error {
+	_ = s.MemoryStore.Add(ctx, k)
+	return s.save()
+}
+
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	_ = s.MemoryStore.Delete(ctx, id)
+	return s.save()
+}
+
+// Touch compares with the last use written to the file, so steady traffic still updates it every TouchInterval.
+func (s *FileStore) Touch(ctx context.Context, id string, at time.Time) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil
+	}
+	_ = s.MemoryStore.Touch(ctx, id, at)
+
+	s.mu.Lock()
+	persisted, ok := s.persisted[id]
+	s.mu.Unlock()
+	if ok && at.Sub(persisted) < s.TouchInterval {
+		return nil
+	}
+	return s.save()
+}
+
+// save writes the keys to a temporary file and renames it over the store.
+func (s *FileStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.MemoryStore.mu.RLock()
+	keys := make([]*Key, 0, len(s.keys))
+	used := make(map[string]time.Time, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+		if k.LastUsedAt != nil {
+			used[k.ID] = *k.LastUsedAt
+		}
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	s.MemoryStore.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	s.persisted = used
+	return nil
+}
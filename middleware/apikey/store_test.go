@@ -0,0 +1,41 @@
+package apikey
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStoreTouchUnderSteadyTraffic(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(ctx, &Key{ID: "k1", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a use every 40 seconds is more often than TouchInterval, the file still follows every minute
+	start := time.Now()
+	for i := 0; i <= 3; i++ {
+		if err := s.Touch(ctx, "k1", start.Add(time.Duration(i)*40*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := loaded.Get(ctx, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Add(80 * time.Second); k.LastUsedAt == nil || k.LastUsedAt.Before(want) {
+		t.Fatalf("persisted last use = %v, want %v", k.LastUsedAt, want)
+	}
+}
@@ -0,0 +1,134 @@
+// Package jwt authenticates requests with JSON Web Tokens from the Authorization header.
+//
+//	auth := jwt.New[*MyClaims](jwt.Config{Keys: jwt.NewJWKS("https://issuer/.well-known/jwks.json"), Issuer: "https://issuer"})
+//	resty.Endpoint(router, initReq, action, auth)
+//
+// Missing, malformed, badly signed and expired tokens fail with errors.ErrorUserUnauthorized. Valid tokens for
+// another issuer or audience, or rejected by Config.Authorize, fail with errors.ErrorInvalidAccess.
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"strings"
+	"time"
+
+	"github.com/porebric/logger"
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/middleware"
+	"github.com/porebric/resty/requests"
+)
+
+// NoLeeway configures a Config without tolerated clock skew, a zero Leeway means the default.
+const NoLeeway time.Duration = -1
+
+type Config struct {
+	Keys KeySource
+	// Algorithms lists the accepted algorithms, all supported ones by default.
+	Algorithms []string
+	Issuer     string
+	// Audience is matched if the token carries any of the values.
+	Audience []string
+	// Leeway is the tolerated clock skew for exp and nbf, 1 minute by default. NoLeeway tolerates none.
+	Leeway time.Duration
+	// RequireExpiresAt rejects tokens without exp.
+	RequireExpiresAt bool
+	// Optional lets requests without a token through, with no claims in the context.
+	Optional bool
+	// Authorize is called with the verified token, returning false fails with errors.ErrorInvalidAccess.
+	Authorize func(ctx context.Context, tok *Token) bool
+}
+
+// New returns a middleware factory verifying the bearer token and putting its claims, decoded into C,
+// into the context.
+func New[C any](cfg Config) func() middleware.Middleware {
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = []string{HS256, RS256, ES256, EdDSA}
+	}
+	switch {
+	case cfg.Leeway == 0:
+		cfg.Leeway = time.Minute
+	case cfg.Leeway < 0:
+		cfg.Leeway = 0
+	}
+
+	return func() middleware.Middleware {
+		return &Auth[C]{cfg: cfg}
+	}
+}
+
+type Auth[C any] struct {
+	next middleware.Middleware
+	cfg  Config
+}
+
+func (a *Auth[C]) Execute(ctx context.Context, req requests.Request) (context.Context, int32, string) {
+	raw := bearer(ctx)
+	if raw == "" {
+		if a.cfg.Optional {
+			return a.next.Execute(ctx, req)
+		}
+		return ctx, errors.ErrorUserUnauthorized, ""
+	}
+
+	tok, err := Parse(raw, a.cfg.Keys, a.cfg.Algorithms)
+	if err == nil {
+		err = tok.Claims.Validate(time.Now(), a.cfg.Leeway, a.cfg.Issuer, a.cfg.Audience, a.cfg.RequireExpiresAt)
+	}
+	if err != nil {
+		logger.Warn(ctx, "jwt rejected", "error", err)
+		if stderrors.Is(err, ErrInvalidIssuer) || stderrors.Is(err, ErrInvalidAudience) {
+			return ctx, errors.ErrorInvalidAccess, ""
+		}
+		return ctx, errors.ErrorUserUnauthorized, ""
+	}
+
+	var claims C
+	if err := json.Unmarshal(tok.Payload, &claims); err != nil {
+		logger.Warn(ctx, "jwt claims", "error", err)
+		return ctx, errors.ErrorUserUnauthorized, ""
+	}
+
+	if a.cfg.Authorize != nil && !a.cfg.Authorize(ctx, tok) {
+		return ctx, errors.ErrorInvalidAccess, ""
+	}
+
+	ctx = context.WithValue(ctx, tokenKey{}, tok)
+	ctx = context.WithValue(ctx, claimsKey[C]{}, claims)
+	return a.next.Execute(ctx, req)
+}
+
+func (a *Auth[C]) SetNext(next middleware.Middleware) {
+	a.next = next
+}
+
+type (
+	tokenKey         struct{}
+	claimsKey[C any] struct{}
+)
+
+// Claims returns the claims put into ctx by a middleware created with the same C.
+func Claims[C any](ctx context.Context) (C, bool) {
+	c, ok := ctx.Value(claimsKey[C]{}).(C)
+	return c, ok
+}
+
+// FromContext returns the verified token of the request.
+func FromContext(ctx context.Context) (*Token, bool) {
+	tok, ok := ctx.Value(tokenKey{}).(*Token)
+	return tok, ok
+}
+
+func bearer(ctx context.Context) string {
+	r := requests.HTTPRequest(ctx)
+	if r == nil {
+		return ""
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
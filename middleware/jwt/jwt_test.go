@@ -0,0 +1,21 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeeway(t *testing.T) {
+	for _, tc := range []struct {
+		leeway, want time.Duration
+	}{
+		{0, time.Minute},
+		{NoLeeway, 0},
+		{5 * time.Second, 5 * time.Second},
+	} {
+		auth := New[map[string]any](Config{Leeway: tc.leeway})().(*Auth[map[string]any])
+		if auth.cfg.Leeway != tc.want {
+			t.Errorf("Leeway %s: got %s, want %s", tc.leeway, auth.cfg.Leeway, tc.want)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// KeySource returns the verification key for the kid and alg of a token: []byte for HS256, *rsa.PublicKey,
+// *ecdsa.PublicKey or ed25519.PublicKey for the others.
+type KeySource interface {
+	Key(kid, alg string) (any, error)
+}
+
+// StaticKeys maps key ids to keys. The key with the empty id is used for tokens without a matching kid.
+type StaticKeys map[string]any
+
+func (k StaticKeys) Key(kid, _ string) (any, error) {
+	if key, ok := k[kid]; ok {
+		return key, nil
+	}
+	if key, ok := k[""]; ok {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// KeysFromFiles reads the files mapped by key id. PEM public keys and certificates are parsed,
+// any other content is used as an HS256 secret.
+func KeysFromFiles(files map[string]string) (StaticKeys, error) {
+	keys := make(StaticKeys, len(files))
+	for kid, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := parseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: key %s: %w", path, err)
+		}
+		keys[kid] = key
+	}
+	return keys, nil
+}
+
+func parseKey(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return data, nil
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+}
+
+// JWKS fetches keys from a JSON Web Key Set URL. The set is refreshed every RefreshInterval and when a token
+// carries an unknown kid, at most once per MinRefreshInterval, so rotated keys are picked up.
+// Failed fetches are retried after MinRefreshInterval too, so an unavailable endpoint is not hammered.
+type JWKS struct {
+	URL                string
+	Client             *http.Client
+	RefreshInterval    time.Duration
+	MinRefreshInterval time.Duration
+
+	mu   sync.Mutex
+	keys map[string]any
+	err  error
+	// fetchedAt is the start of the last attempt, fetching is closed when the running one finishes.
+	fetchedAt time.Time
+	fetching  chan struct{}
+}
+
+func NewJWKS(url string) *JWKS {
+	return &JWKS{URL: url}
+}
+
+// Key fetches the set outside the lock, concurrent callers share the fetch. Callers whose kid is known
+// keep using the current keys while it runs.
+func (j *JWKS) Key(kid, _ string) (any, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	refresh, minRefresh := j.RefreshInterval, j.MinRefreshInterval
+	if refresh <= 0 {
+		refresh = time.Hour
+	}
+	if minRefresh <= 0 {
+		minRefresh = time.Minute
+	}
+
+	since := time.Since(j.fetchedAt)
+	_, known := j.keys[kid]
+	switch {
+	case j.fetching != nil && !known:
+		done := j.fetching
+		j.mu.Unlock()
+		<-done
+		j.mu.Lock()
+	case j.fetching == nil && (j.fetchedAt.IsZero() || since > refresh || !known && since > minRefresh):
+		done := make(chan struct{})
+		j.fetching, j.fetchedAt = done, time.Now()
+		j.mu.Unlock()
+
+		keys, err := j.fetch()
+
+		j.mu.Lock()
+		if err == nil {
+			j.keys = keys
+		}
+		j.err, j.fetching = err, nil
+		close(done)
+	}
+
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, nil
+		}
+	}
+	if j.keys == nil && j.err != nil {
+		return nil, j.err
+	}
+	return nil, ErrKeyNotFound
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func (j *JWKS) fetch() (map[string]any, error) {
+	client := j.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	resp, err := client.Get(j.URL)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwt: fetch jwks: status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("jwt: decode jwks: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.key(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+
+	return keys, nil
+}
+
+func (k jwk) key() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("jwt: unsupported curve %s", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("jwt: unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwt: invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	default:
+		return nil, fmt.Errorf("jwt: unsupported key type %s", k.Kty)
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
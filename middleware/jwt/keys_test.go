@@ -0,0 +1,133 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type jwksServer struct {
+	*httptest.Server
+	fetches atomic.Int32
+	fail    atomic.Bool
+	delay   atomic.Int64
+	kids    atomic.Value
+}
+
+func newJWKSServer(t *testing.T, kids ...string) *jwksServer {
+	t.Helper()
+
+	s := new(jwksServer)
+	s.kids.Store(kids)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		s.fetches.Add(1)
+		time.Sleep(time.Duration(s.delay.Load()))
+
+		if s.fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var set struct {
+			Keys []jwk `json:"keys"`
+		}
+		for _, kid := range s.kids.Load().([]string) {
+			set.Keys = append(set.Keys, jwk{Kid: kid, Kty: "oct", K: base64.RawURLEncoding.EncodeToString([]byte("secret-" + kid))})
+		}
+		_ = json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestJWKSSharesConcurrentFetch(t *testing.T) {
+	srv := newJWKSServer(t, "a")
+	srv.delay.Store(int64(50 * time.Millisecond))
+	keys := NewJWKS(srv.URL)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := keys.Key("a", "HS256"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("fetches = %d, want 1", n)
+	}
+}
+
+func TestJWKSKnownKeyDoesNotWaitForRefresh(t *testing.T) {
+	srv := newJWKSServer(t, "a")
+	keys := &JWKS{URL: srv.URL, MinRefreshInterval: time.Millisecond}
+
+	if _, err := keys.Key("a", "HS256"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.delay.Store(int64(300 * time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+
+	// an unknown kid starts a slow refresh
+	go func() { _, _ = keys.Key("b", "HS256") }()
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := keys.Key("a", "HS256"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 150*time.Millisecond {
+		t.Fatalf("known key waited %s for the refresh", d)
+	}
+}
+
+func TestJWKSFailedFetchIsNotRetriedImmediately(t *testing.T) {
+	srv := newJWKSServer(t, "a")
+	srv.fail.Store(true)
+	keys := &JWKS{URL: srv.URL, MinRefreshInterval: time.Hour}
+
+	for i := 0; i < 5; i++ {
+		if _, err := keys.Key("a", "HS256"); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("fetches = %d, want 1", n)
+	}
+}
+
+func TestJWKSRefreshesOnUnknownKid(t *testing.T) {
+	srv := newJWKSServer(t, "a")
+	keys := &JWKS{URL: srv.URL, MinRefreshInterval: 20 * time.Millisecond}
+
+	if _, err := keys.Key("a", "HS256"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.kids.Store([]string{"a", "b"})
+	if _, err := keys.Key("b", "HS256"); err != ErrKeyNotFound {
+		t.Fatalf("err = %v, want ErrKeyNotFound before MinRefreshInterval", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	key, err := keys.Key("b", "HS256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(key.([]byte)) != "secret-b" {
+		t.Fatalf("key = %q", key)
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Fatalf("fetches = %d, want 2", n)
+	}
+}
@@ -0,0 +1,227 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+var (
+	ErrMalformed        = stderrors.New("jwt: malformed token")
+	ErrAlgorithm        = stderrors.New("jwt: algorithm not allowed")
+	ErrSignature        = stderrors.New("jwt: invalid signature")
+	ErrKeyNotFound      = stderrors.New("jwt: key not found")
+	ErrExpired          = stderrors.New("jwt: token is expired")
+	ErrNotValidYet      = stderrors.New("jwt: token is not valid yet")
+	ErrInvalidIssuer    = stderrors.New("jwt: invalid issuer")
+	ErrInvalidAudience  = stderrors.New("jwt: invalid audience")
+	ErrMissingExpiresAt = stderrors.New("jwt: exp is required")
+)
+
+type Header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// NumericDate is a JSON number of seconds since the epoch.
+type NumericDate struct {
+	time.Time
+}
+
+func (d *NumericDate) UnmarshalJSON(b []byte) error {
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return err
+	}
+	sec := int64(f)
+	d.Time = time.Unix(sec, int64((f-float64(sec))*1e9))
+	return nil
+}
+
+func (d NumericDate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(d.Unix(), 10)), nil
+}
+
+// Audience is a single string or an array of strings.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// RegisteredClaims are the claims checked by the middleware.
+type RegisteredClaims struct {
+	Issuer    string       `json:"iss,omitempty"`
+	Subject   string       `json:"sub,omitempty"`
+	Audience  Audience     `json:"aud,omitempty"`
+	ExpiresAt *NumericDate `json:"exp,omitempty"`
+	NotBefore *NumericDate `json:"nbf,omitempty"`
+	IssuedAt  *NumericDate `json:"iat,omitempty"`
+	ID        string       `json:"jti,omitempty"`
+}
+
+// Token is a parsed and verified token.
+type Token struct {
+	Header  Header
+	Claims  RegisteredClaims
+	Payload json.RawMessage
+}
+
+// Parse verifies the signature of raw with a key from keys and decodes its registered claims.
+// The claims themselves are checked by Validate.
+func Parse(raw string, keys KeySource, algorithms []string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var tok Token
+	if err := decodeSegment(parts[0], &tok.Header); err != nil {
+		return nil, ErrMalformed
+	}
+
+	allowed := false
+	for _, alg := range algorithms {
+		allowed = allowed || alg == tok.Header.Alg
+	}
+	if !allowed {
+		return nil, ErrAlgorithm
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	key, err := keys.Key(tok.Header.Kid, tok.Header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(tok.Header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if err := json.Unmarshal(payload, &tok.Claims); err != nil {
+		return nil, ErrMalformed
+	}
+	tok.Payload = payload
+
+	return &tok, nil
+}
+
+// Validate checks exp, nbf, iss and aud. leeway is the tolerated clock skew.
+func (c *RegisteredClaims) Validate(now time.Time, leeway time.Duration, issuer string, audience []string, requireExp bool) error {
+	if c.ExpiresAt == nil && requireExp {
+		return ErrMissingExpiresAt
+	}
+	if c.ExpiresAt != nil && !now.Before(c.ExpiresAt.Add(leeway)) {
+		return ErrExpired
+	}
+	if c.NotBefore != nil && now.Add(leeway).Before(c.NotBefore.Time) {
+		return ErrNotValidYet
+	}
+	if issuer != "" && c.Issuer != issuer {
+		return ErrInvalidIssuer
+	}
+
+	if len(audience) == 0 {
+		return nil
+	}
+	for _, want := range audience {
+		for _, got := range c.Audience {
+			if want == got {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidAudience
+}
+
+func verify(alg string, key any, signed, sig []byte) error {
+	switch alg {
+	case HS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrAlgorithm
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return ErrSignature
+		}
+	case RS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrAlgorithm
+		}
+		sum := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) != nil {
+			return ErrSignature
+		}
+	case ES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrAlgorithm
+		}
+		if len(sig) != 64 {
+			return ErrSignature
+		}
+		sum := sha256.Sum256(signed)
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, sum[:], r, s) {
+			return ErrSignature
+		}
+	case EdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return ErrAlgorithm
+		}
+		if !ed25519.Verify(pub, signed, sig) {
+			return ErrSignature
+		}
+	default:
+		return ErrAlgorithm
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
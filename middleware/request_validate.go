@@ -2,29 +2,40 @@ package middleware
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/porebric/resty/errors"
 	"github.com/porebric/resty/requests"
+	"github.com/porebric/resty/validation"
 )
 
+// RequestValidate checks the `validate` struct tags of the request and its Validate method.
+// All violations are put into the context, so the error response can list every field.
 type RequestValidate struct {
 	next Middleware
 }
 
 func (r *RequestValidate) Execute(ctx context.Context, req requests.Request) (context.Context, int32, string) {
+	result := validation.Struct(req)
+
 	valid, field, msg := req.Validate()
-	if valid {
-		return r.next.Execute(ctx, req)
-	}
-	if field == "" {
-		return ctx, errors.ErrorInvalidRequest, ""
+	if !valid {
+		if field == "" && result.Valid() {
+			return ctx, errors.ErrorInvalidRequest, ""
+		}
+
+		if field != "" {
+			if msg == "" {
+				msg = "invalid"
+			}
+			result.Add(field, "custom", msg)
+		}
 	}
 
-	if msg == "" {
-		msg = "invalid"
+	if result.Valid() {
+		return r.next.Execute(ctx, req)
 	}
-	return ctx, errors.ErrorInvalidRequest, fmt.Sprintf(`%s: %s`, field, msg)
+
+	return validation.ToContext(ctx, result), errors.ErrorInvalidRequest, result.Error()
 }
 
 func (r *RequestValidate) SetNext(next Middleware) {
@@ -0,0 +1,76 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"strings"
+)
+
+var (
+	ErrInvalidCursor = stderrors.New("invalid cursor")
+	ErrShortSecret   = stderrors.New("pagination: cursor secret must be at least 32 bytes")
+)
+
+// Cursors encodes cursor values into opaque strings signed with HMAC-SHA256, so clients cannot forge them.
+type Cursors struct {
+	secret []byte
+}
+
+// NewCursors returns ErrShortSecret for secrets shorter than 32 bytes, they would make cursors forgeable.
+func NewCursors(secret []byte) (*Cursors, error) {
+	if len(secret) < 32 {
+		return nil, ErrShortSecret
+	}
+	return &Cursors{secret: secret}, nil
+}
+
+func (c *Cursors) Encode(v any) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload)), nil
+}
+
+func (c *Cursors) Decode(cursor string, v any) error {
+	payload, err := c.verify(cursor)
+	if err != nil {
+		return err
+	}
+
+	if err = json.Unmarshal(payload, v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+func (c *Cursors) verify(cursor string) ([]byte, error) {
+	encPayload, encSig, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encSig)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	if !hmac.Equal(sig, c.sign(payload)) {
+		return nil, ErrInvalidCursor
+	}
+	return payload, nil
+}
+
+func (c *Cursors) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
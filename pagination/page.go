@@ -0,0 +1,114 @@
+package pagination
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/porebric/resty/responses"
+)
+
+type Meta struct {
+	Limit      int    `json:"limit"`
+	Offset     *int   `json:"offset,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// Page is the standard list response. It sets the Link header with the next, prev and first pages.
+type Page[T any] struct {
+	responses.Head `json:"-"`
+	Items          []T  `json:"items"`
+	Meta           Meta `json:"meta"`
+}
+
+// NewOffsetPage builds a page for offset pagination. A negative total means the total is unknown,
+// then a full page is assumed to have a next one.
+func NewOffsetPage[T any](p Params, items []T, total int64) *Page[T] {
+	if items == nil {
+		items = make([]T, 0)
+	}
+
+	offset := p.Offset
+	page := &Page[T]{Items: items, Meta: Meta{Limit: p.Limit, Offset: &offset}}
+	if total >= 0 {
+		page.Meta.Total = &total
+	}
+
+	// without a limit there is no page to move to, the links would point back to this one
+	links := make([]string, 0, 3)
+	if p.Limit > 0 && (total < 0 && len(items) >= p.Limit || total >= 0 && int64(p.Offset+p.Limit) < total) {
+		links = append(links, link(p, "next", map[string]string{ParamOffset: strconv.Itoa(p.Offset + p.Limit)}))
+	}
+	if p.Limit > 0 && p.Offset > 0 {
+		links = append(links, link(p, "prev", map[string]string{ParamOffset: strconv.Itoa(max(p.Offset-p.Limit, 0))}))
+	}
+	links = append(links, link(p, "first", map[string]string{ParamOffset: ""}))
+
+	page.setLinks(links)
+	return page
+}
+
+// NewCursorPage builds a page for cursor pagination. Empty cursors mean there is no such page.
+func NewCursorPage[T any](p Params, items []T, next, prev string) *Page[T] {
+	if items == nil {
+		items = make([]T, 0)
+	}
+
+	page := &Page[T]{Items: items, Meta: Meta{Limit: p.Limit, NextCursor: next, PrevCursor: prev}}
+
+	links := make([]string, 0, 3)
+	if next != "" {
+		links = append(links, link(p, "next", map[string]string{ParamCursor: next, ParamOffset: ""}))
+	}
+	if prev != "" {
+		links = append(links, link(p, "prev", map[string]string{ParamCursor: prev, ParamOffset: ""}))
+	}
+	links = append(links, link(p, "first", map[string]string{ParamCursor: "", ParamOffset: ""}))
+
+	page.setLinks(links)
+	return page
+}
+
+func (r *Page[T]) PrepareResponse(w http.ResponseWriter) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+func (r *Page[T]) String() string {
+	body, _ := json.Marshal(r.Meta)
+	return fmt.Sprintf(`{"items":%d,"meta":%s}`, len(r.Items), body)
+}
+
+// setLinks sets the Link header, pages bound without a request URL have none.
+func (r *Page[T]) setLinks(links []string) {
+	links = slices.DeleteFunc(links, func(l string) bool { return l == "" })
+	if len(links) != 0 {
+		r.SetHeader("Link", strings.Join(links, ", "))
+	}
+}
+
+// link returns the request URL with the effective limit and the query parameters replaced by params,
+// an empty value removes the parameter.
+func link(p Params, rel string, params map[string]string) string {
+	if p.URL == nil {
+		return ""
+	}
+
+	u := *p.URL
+	query := u.Query()
+	query.Set(ParamLimit, strconv.Itoa(p.Limit))
+	for k, v := range params {
+		if v == "" {
+			query.Del(k)
+		} else {
+			query.Set(k, v)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+}
@@ -0,0 +1,116 @@
+// Package pagination binds limit, offset and cursor parameters and renders pages with RFC 8288 Link headers.
+package pagination
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/requests"
+)
+
+const (
+	ParamLimit  = "limit"
+	ParamOffset = "offset"
+	ParamCursor = "cursor"
+)
+
+type Options struct {
+	// DefaultLimit is used when the request has no limit, 20 by default.
+	DefaultLimit int
+	// MaxLimit caps the requested limit, 100 by default.
+	MaxLimit int
+	// Cursors verifies the cursor parameter. Requests with a cursor are rejected when it is nil.
+	Cursors *Cursors
+}
+
+func (o Options) withDefaults() Options {
+	if o.DefaultLimit <= 0 {
+		o.DefaultLimit = 20
+	}
+	if o.MaxLimit <= 0 {
+		o.MaxLimit = 100
+	}
+	if o.DefaultLimit > o.MaxLimit {
+		o.DefaultLimit = o.MaxLimit
+	}
+	return o
+}
+
+// Params are the page parameters of a request.
+type Params struct {
+	Limit  int
+	Offset int
+	Cursor string
+	URL    *url.URL
+
+	cursors *Cursors
+}
+
+// Bind reads the page parameters from the query of r. A limit above the maximum is lowered to it.
+func Bind(r *http.Request, opts Options) (Params, error) {
+	opts = opts.withDefaults()
+	query := r.URL.Query()
+
+	p := Params{Limit: opts.DefaultLimit, URL: r.URL, cursors: opts.Cursors}
+
+	if v := query.Get(ParamLimit); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return p, errors.New(errors.ErrorInvalidRequest, fmt.Sprintf("%s: must be a positive number", ParamLimit))
+		}
+		p.Limit = min(limit, opts.MaxLimit)
+	}
+
+	if v := query.Get(ParamOffset); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return p, errors.New(errors.ErrorInvalidRequest, fmt.Sprintf("%s: must not be negative", ParamOffset))
+		}
+		p.Offset = offset
+	}
+
+	if v := query.Get(ParamCursor); v != "" {
+		if opts.Cursors == nil {
+			return p, errors.New(errors.ErrorInvalidRequest, fmt.Sprintf("%s: not supported", ParamCursor))
+		}
+		if _, err := opts.Cursors.verify(v); err != nil {
+			return p, errors.New(errors.ErrorInvalidRequest, fmt.Sprintf("%s: invalid", ParamCursor))
+		}
+		p.Cursor = v
+	}
+
+	return p, nil
+}
+
+// DecodeCursor unmarshals the verified cursor into v. It reports false if the request has no cursor.
+func (p Params) DecodeCursor(v any) (bool, error) {
+	if p.Cursor == "" || p.cursors == nil {
+		return false, nil
+	}
+	return true, p.cursors.Decode(p.Cursor, v)
+}
+
+// EncodeCursor signs v with the cursors the params were bound with.
+func (p Params) EncodeCursor(v any) (string, error) {
+	if p.cursors == nil {
+		return "", fmt.Errorf("pagination: no cursors configured")
+	}
+	return p.cursors.Encode(v)
+}
+
+// Parameters documents the page parameters for RouteSpec.Params.
+func Parameters(opts Options) []requests.Param {
+	opts = opts.withDefaults()
+
+	params := []requests.Param{
+		{Name: ParamLimit, In: "query", Type: "integer", Description: fmt.Sprintf("page size, %d by default, at most %d", opts.DefaultLimit, opts.MaxLimit)},
+		{Name: ParamOffset, In: "query", Type: "integer", Description: "number of items to skip"},
+	}
+	if opts.Cursors != nil {
+		params = append(params, requests.Param{Name: ParamCursor, In: "query", Type: "string", Description: "opaque cursor from a previous page"})
+	}
+	return params
+}
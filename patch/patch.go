@@ -0,0 +1,392 @@
+// Package patch applies JSON Patch (RFC 6902) and JSON Merge Patch (RFC 7396) documents.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	ContentTypeJSONPatch  = "application/json-patch+json"
+	ContentTypeMergePatch = "application/merge-patch+json"
+)
+
+// Error describes why an operation could not be applied. Test is set when a test operation failed.
+type Error struct {
+	Index   int
+	Op      string
+	Path    string
+	Message string
+	Test    bool
+}
+
+func (e *Error) Error() string {
+	if e.Op == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("operation %d (%s %s): %s", e.Index, e.Op, e.Path, e.Message)
+}
+
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply applies a JSON Patch to doc. Operations are applied in order and the first failure aborts the patch.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var ops []Operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, &Error{Message: "patch must be an array of operations"}
+	}
+
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range ops {
+		if target, err = apply(target, op); err != nil {
+			if e, ok := err.(*Error); ok {
+				e.Index, e.Op, e.Path = i, op.Op, op.Path
+			}
+			return nil, err
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+// Merge applies a JSON Merge Patch to doc: objects are merged recursively, null removes a member and
+// anything else replaces the target.
+func Merge(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decode(patch)
+	if err != nil {
+		return nil, &Error{Message: "invalid merge patch"}
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any)
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = merge(t[k], v)
+	}
+	return t
+}
+
+func apply(doc any, op Operation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, &Error{Message: "value is required"}
+		}
+
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			return replace(doc, path, value)
+		default:
+			current, err := get(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !equal(current, value) {
+				return nil, &Error{Message: "test failed", Test: true}
+			}
+			return doc, nil
+		}
+
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		var value any
+		if op.Op == "move" {
+			if op.From == op.Path {
+				return doc, nil
+			}
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return nil, &Error{Message: "cannot move a value into itself"}
+			}
+			if doc, value, err = remove(doc, from); err != nil {
+				return nil, err
+			}
+		} else {
+			if value, err = get(doc, from); err != nil {
+				return nil, err
+			}
+			if value, err = clone(value); err != nil {
+				return nil, err
+			}
+		}
+		return add(doc, path, value)
+
+	default:
+		return nil, &Error{Message: fmt.Sprintf("unknown operation %q", op.Op)}
+	}
+}
+
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch d := doc.(type) {
+		case map[string]any:
+			v, ok := d[token]
+			if !ok {
+				return nil, errPath()
+			}
+			doc = v
+		case []any:
+			i, err := index(token, len(d)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = d[i]
+		default:
+			return nil, errPath()
+		}
+	}
+	return doc, nil
+}
+
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token, last := path[0], len(path) == 1
+
+	switch d := doc.(type) {
+	case map[string]any:
+		if last {
+			d[token] = value
+			return d, nil
+		}
+
+		child, ok := d[token]
+		if !ok {
+			return nil, errPath()
+		}
+		child, err := add(child, path[1:], value)
+		d[token] = child
+		return d, err
+
+	case []any:
+		if last {
+			i := len(d)
+			if token != "-" {
+				var err error
+				if i, err = index(token, len(d)); err != nil {
+					return nil, err
+				}
+			}
+			d = append(d, nil)
+			copy(d[i+1:], d[i:])
+			d[i] = value
+			return d, nil
+		}
+
+		i, err := index(token, len(d)-1)
+		if err != nil {
+			return nil, err
+		}
+		d[i], err = add(d[i], path[1:], value)
+		return d, err
+
+	default:
+		return nil, errPath()
+	}
+}
+
+func replace(doc any, path []string, value any) (any, error) {
+	if _, err := get(doc, path); err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	token := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		p[token] = value
+	case []any:
+		i, _ := index(token, len(p)-1)
+		p[i] = value
+	}
+	return doc, nil
+}
+
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, &Error{Message: "cannot remove the whole document"}
+	}
+
+	token := path[0]
+	switch d := doc.(type) {
+	case map[string]any:
+		child, ok := d[token]
+		if !ok {
+			return nil, nil, errPath()
+		}
+		if len(path) == 1 {
+			delete(d, token)
+			return d, child, nil
+		}
+
+		child, removed, err := remove(child, path[1:])
+		d[token] = child
+		return d, removed, err
+
+	case []any:
+		i, err := index(token, len(d)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(path) == 1 {
+			removed := d[i]
+			return append(d[:i], d[i+1:]...), removed, nil
+		}
+
+		child, removed, err := remove(d[i], path[1:])
+		d[i] = child
+		return d, removed, err
+
+	default:
+		return nil, nil, errPath()
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, &Error{Message: fmt.Sprintf("invalid pointer %q", pointer)}
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// index parses an array index in the range [0, max].
+func index(token string, max int) (int, error) {
+	if token == "" || len(token) > 1 && token[0] == '0' {
+		return 0, errPath()
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max {
+		return 0, errPath()
+	}
+	return i, nil
+}
+
+func errPath() error {
+	return &Error{Message: "path not found"}
+}
+
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, &Error{Message: "invalid json"}
+	}
+	return v, nil
+}
+
+func clone(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+func equal(a, b any) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			if w, exists := y[k]; !exists || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,75 @@
+package resty
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/middleware"
+	"github.com/porebric/resty/patch"
+	"github.com/porebric/resty/requests"
+	"github.com/porebric/resty/responses"
+)
+
+// maxPatchSize limits the body of patch requests.
+const maxPatchSize = 1 << 20
+
+// PatchEndpoint registers a PATCH endpoint accepting application/json-patch+json and application/merge-patch+json.
+// fetch loads the current resource, the patch is applied to its JSON representation and the result is decoded
+// into a new R, which goes through validation and mm like any other request before action is called.
+// Fields of R that are not part of its JSON representation are not carried over from the fetched value.
+func PatchEndpoint[R requests.Request](r Router, spec RouteSpec, fetch func(ctx context.Context, r *http.Request) (context.Context, R, error), action func(context.Context, R) (responses.Response, int), mm ...func() middleware.Middleware) {
+	if len(spec.Methods) == 0 {
+		spec.Methods = []string{http.MethodPatch}
+	}
+
+	EndpointWithSpec(r, spec, func(ctx context.Context, r *http.Request) (context.Context, R, error) {
+		req := newRequest[R]()
+
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if contentType != patch.ContentTypeJSONPatch && contentType != patch.ContentTypeMergePatch {
+			return ctx, req, errors.New(errors.ErrorUnsupportedMediaType, "expected "+patch.ContentTypeJSONPatch+" or "+patch.ContentTypeMergePatch)
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxPatchSize))
+		if err != nil {
+			return ctx, req, errors.New(errors.ErrorRequestTooLarge, "")
+		}
+
+		ctx, current, err := fetch(ctx, r)
+		if err != nil {
+			return ctx, req, err
+		}
+
+		doc, err := json.Marshal(current)
+		if err != nil {
+			return ctx, req, err
+		}
+
+		if contentType == patch.ContentTypeJSONPatch {
+			doc, err = patch.Apply(doc, body)
+		} else {
+			doc, err = patch.Merge(doc, body)
+		}
+
+		var patchErr *patch.Error
+		switch {
+		case stderrors.As(err, &patchErr) && patchErr.Test:
+			return ctx, req, errors.New(errors.ErrorConflict, patchErr.Error())
+		case stderrors.As(err, &patchErr):
+			return ctx, req, errors.New(errors.ErrorInvalidRequest, patchErr.Error())
+		case err != nil:
+			return ctx, req, err
+		}
+
+		if err = json.Unmarshal(doc, &req); err != nil {
+			return ctx, req, errors.New(errors.ErrorInvalidRequest, "patched document does not match the resource")
+		}
+
+		return ctx, req, nil
+	}, action, mm...)
+}
@@ -0,0 +1,111 @@
+package query
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/porebric/resty/responses"
+)
+
+// Prune keeps only fields of the JSON representation of v. Arrays are pruned element by element and
+// nested fields are addressed with dots, like "author.name". No fields keep everything.
+func Prune(v any, fields []string) (any, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc any
+	if err = json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 0 {
+		return doc, nil
+	}
+	return prune(doc, fields), nil
+}
+
+func prune(doc any, fields []string) any {
+	switch d := doc.(type) {
+	case []any:
+		for i := range d {
+			d[i] = prune(d[i], fields)
+		}
+		return d
+	case map[string]any:
+		kept := make(map[string]any, len(fields))
+		nested := make(map[string][]string)
+		for _, f := range fields {
+			name, rest, ok := strings.Cut(f, ".")
+			if !ok {
+				if v, exists := d[name]; exists {
+					kept[name] = v
+				}
+				continue
+			}
+			nested[name] = append(nested[name], rest)
+		}
+		for name, sub := range nested {
+			if _, whole := kept[name]; whole {
+				continue
+			}
+			if v, exists := d[name]; exists {
+				kept[name] = prune(v, sub)
+			}
+		}
+		return kept
+	default:
+		return doc
+	}
+}
+
+// Sparse renders Data with only the requested Fields. If Path is set, only the value under that top-level
+// key is pruned, e.g. "items" of a pagination page.
+type Sparse struct {
+	responses.Head
+	Data   any
+	Fields []string
+	Path   string
+}
+
+// ResponseHead returns the head of Data when Sparse has none of its own, so wrapped pages keep their links.
+func (r *Sparse) ResponseHead() *responses.Head {
+	if headed, ok := r.Data.(responses.Headed); ok && r.Status == 0 && r.Header == nil && r.Cookies == nil {
+		return headed.ResponseHead()
+	}
+	return &r.Head
+}
+
+func (r *Sparse) PrepareResponse(w http.ResponseWriter) error {
+	doc, err := r.pruned()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(doc)
+}
+
+func (r *Sparse) String() string {
+	doc, _ := r.pruned()
+	body, _ := json.Marshal(doc)
+	return string(body)
+}
+
+func (r *Sparse) pruned() (any, error) {
+	if r.Path == "" {
+		return Prune(r.Data, r.Fields)
+	}
+
+	doc, err := Prune(r.Data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if m, ok := doc.(map[string]any); ok {
+		if v, exists := m[r.Path]; exists {
+			m[r.Path] = prune(v, r.Fields)
+		}
+	}
+	return doc, nil
+}
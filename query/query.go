@@ -0,0 +1,242 @@
+// Package query parses filtering, sorting and sparse fieldset parameters against an allow list:
+//
+//	?filter[status]=active&filter[age][gte]=18&filter[role][in]=admin,owner&sort=-created,name&fields=id,name
+//
+// Anything not declared in the Spec of the request type is rejected.
+package query
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/requests"
+)
+
+type Op string
+
+const (
+	Eq     Op = "eq"
+	Ne     Op = "ne"
+	Gt     Op = "gt"
+	Gte    Op = "gte"
+	Lt     Op = "lt"
+	Lte    Op = "lte"
+	In     Op = "in"
+	Like   Op = "like"
+	IsNull Op = "null"
+)
+
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Float
+	Bool
+	Time
+)
+
+var typeNames = map[Type]string{String: "string", Int: "integer", Float: "number", Bool: "boolean", Time: "string"}
+
+// Field declares a filterable field, its value type and the allowed operators. Eq is allowed if Ops is empty.
+type Field struct {
+	Type Type
+	Ops  []Op
+}
+
+// Spec is the allow list of a list endpoint.
+type Spec struct {
+	Filters map[string]Field
+	Sort    []string
+	Fields  []string
+	// DefaultSort is used when the request has no sort parameter.
+	DefaultSort []Sort
+}
+
+// Declarer is implemented by request types that accept query parameters.
+type Declarer interface {
+	QuerySpec() Spec
+}
+
+// Filter is a single condition. Value has the declared type of the field: string, int64, float64, bool or
+// time.Time, a slice of it for In and a bool for IsNull.
+type Filter struct {
+	Field string
+	Op    Op
+	Value any
+}
+
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+type Query struct {
+	Filters []Filter
+	Sort    []Sort
+	Fields  []string
+}
+
+// Bind parses the query of r with the spec declared by d.
+func Bind(r *http.Request, d Declarer) (*Query, error) {
+	return Parse(r.URL.Query(), d.QuerySpec())
+}
+
+func Parse(values url.Values, spec Spec) (*Query, error) {
+	q := &Query{Sort: spec.DefaultSort}
+
+	for key, vv := range values {
+		if !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+
+		filter, err := parseFilter(key, vv[len(vv)-1], spec)
+		if err != nil {
+			return nil, errors.New(errors.ErrorInvalidRequest, fmt.Sprintf("%s: %s", key, err))
+		}
+		q.Filters = append(q.Filters, filter)
+	}
+	slices.SortFunc(q.Filters, func(a, b Filter) int { return strings.Compare(a.Field+string(a.Op), b.Field+string(b.Op)) })
+
+	if v := values.Get("sort"); v != "" {
+		q.Sort = nil
+		for _, s := range strings.Split(v, ",") {
+			sort := Sort{Field: strings.TrimSpace(s)}
+			if strings.HasPrefix(sort.Field, "-") {
+				sort.Field, sort.Desc = sort.Field[1:], true
+			}
+			if !slices.Contains(spec.Sort, sort.Field) {
+				return nil, errors.New(errors.ErrorInvalidRequest, fmt.Sprintf("sort: %s is not sortable", sort.Field))
+			}
+			q.Sort = append(q.Sort, sort)
+		}
+	}
+
+	if v := values.Get("fields"); v != "" {
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimSpace(f)
+			if !slices.Contains(spec.Fields, f) {
+				return nil, errors.New(errors.ErrorInvalidRequest, fmt.Sprintf("fields: unknown field %s", f))
+			}
+			q.Fields = append(q.Fields, f)
+		}
+	}
+
+	return q, nil
+}
+
+// parseFilter parses filter[field] and filter[field][op].
+func parseFilter(key, value string, spec Spec) (Filter, error) {
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(key, "filter["), "]"), "][")
+
+	filter := Filter{Field: parts[0], Op: Eq}
+	switch len(parts) {
+	case 1:
+	case 2:
+		filter.Op = Op(parts[1])
+	default:
+		return filter, fmt.Errorf("invalid filter")
+	}
+
+	field, ok := spec.Filters[filter.Field]
+	if !ok {
+		return filter, fmt.Errorf("field is not filterable")
+	}
+
+	ops := field.Ops
+	if len(ops) == 0 {
+		ops = []Op{Eq}
+	}
+	if !slices.Contains(ops, filter.Op) {
+		return filter, fmt.Errorf("operator is not allowed")
+	}
+
+	var err error
+	switch filter.Op {
+	case IsNull:
+		filter.Value, err = strconv.ParseBool(value)
+	case In:
+		values := make([]any, 0)
+		for _, v := range strings.Split(value, ",") {
+			typed, err := parseValue(strings.TrimSpace(v), field.Type)
+			if err != nil {
+				return filter, err
+			}
+			values = append(values, typed)
+		}
+		filter.Value = values
+	default:
+		filter.Value, err = parseValue(value, field.Type)
+	}
+
+	return filter, err
+}
+
+func parseValue(value string, t Type) (any, error) {
+	var (
+		v   any
+		err error
+	)
+
+	switch t {
+	case Int:
+		v, err = strconv.ParseInt(value, 10, 64)
+	case Float:
+		v, err = strconv.ParseFloat(value, 64)
+	case Bool:
+		v, err = strconv.ParseBool(value)
+	case Time:
+		v, err = time.Parse(time.RFC3339, value)
+	default:
+		v = value
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", value)
+	}
+	return v, nil
+}
+
+// Parameters documents the filter, sort and fields parameters of spec for RouteSpec.Params.
+func Parameters(spec Spec) []requests.Param {
+	params := make([]requests.Param, 0, len(spec.Filters)+2)
+
+	names := make([]string, 0, len(spec.Filters))
+	for name := range spec.Filters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		field := spec.Filters[name]
+		ops := field.Ops
+		if len(ops) == 0 {
+			ops = []Op{Eq}
+		}
+
+		opNames := make([]string, 0, len(ops))
+		for _, op := range ops {
+			opNames = append(opNames, string(op))
+		}
+		params = append(params, requests.Param{
+			Name:        fmt.Sprintf("filter[%s][op]", name),
+			In:          "query",
+			Type:        typeNames[field.Type],
+			Description: "operators: " + strings.Join(opNames, ", "),
+		})
+	}
+
+	if len(spec.Sort) != 0 {
+		params = append(params, requests.Param{Name: "sort", In: "query", Type: "string", Description: "comma separated, - for descending: " + strings.Join(spec.Sort, ", ")})
+	}
+	if len(spec.Fields) != 0 {
+		params = append(params, requests.Param{Name: "fields", In: "query", Type: "string", Description: "comma separated: " + strings.Join(spec.Fields, ", ")})
+	}
+	return params
+}
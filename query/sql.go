@@ -0,0 +1,88 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SQLOptions maps the query to SQL. Columns renames fields, unknown fields are used as is because they
+// already passed the allow list. Placeholder returns the n-th (1-based) argument placeholder, "?" by default.
+type SQLOptions struct {
+	Columns     map[string]string
+	Placeholder func(n int) string
+}
+
+// Dollar numbers placeholders as PostgreSQL expects.
+func Dollar(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// SQL returns the WHERE condition without the keyword, its arguments and the ORDER BY list.
+// Empty strings mean there is nothing to filter or sort by.
+func (q *Query) SQL(opts SQLOptions) (where string, args []any, orderBy string) {
+	if opts.Placeholder == nil {
+		opts.Placeholder = func(int) string { return "?" }
+	}
+
+	column := func(field string) string {
+		if c, ok := opts.Columns[field]; ok {
+			return c
+		}
+		return field
+	}
+	arg := func(v any) string {
+		args = append(args, v)
+		return opts.Placeholder(len(args))
+	}
+
+	conditions := make([]string, 0, len(q.Filters))
+	for _, f := range q.Filters {
+		col := column(f.Field)
+
+		switch f.Op {
+		case Eq:
+			conditions = append(conditions, fmt.Sprintf("%s = %s", col, arg(f.Value)))
+		case Ne:
+			conditions = append(conditions, fmt.Sprintf("%s <> %s", col, arg(f.Value)))
+		case Gt:
+			conditions = append(conditions, fmt.Sprintf("%s > %s", col, arg(f.Value)))
+		case Gte:
+			conditions = append(conditions, fmt.Sprintf("%s >= %s", col, arg(f.Value)))
+		case Lt:
+			conditions = append(conditions, fmt.Sprintf("%s < %s", col, arg(f.Value)))
+		case Lte:
+			conditions = append(conditions, fmt.Sprintf("%s <= %s", col, arg(f.Value)))
+		case Like:
+			conditions = append(conditions, fmt.Sprintf(`%s LIKE %s ESCAPE '\'`, col, arg("%"+escapeLike(fmt.Sprint(f.Value))+"%")))
+		case IsNull:
+			if isNull, _ := f.Value.(bool); isNull {
+				conditions = append(conditions, col+" IS NULL")
+			} else {
+				conditions = append(conditions, col+" IS NOT NULL")
+			}
+		case In:
+			values, _ := f.Value.([]any)
+			placeholders := make([]string, 0, len(values))
+			for _, v := range values {
+				placeholders = append(placeholders, arg(v))
+			}
+			conditions = append(conditions, fmt.Sprintf("%s IN (%s)", col, strings.Join(placeholders, ", ")))
+		}
+	}
+
+	order := make([]string, 0, len(q.Sort))
+	for _, s := range q.Sort {
+		if s.Desc {
+			order = append(order, column(s.Field)+" DESC")
+		} else {
+			order = append(order, column(s.Field)+" ASC")
+		}
+	}
+
+	return strings.Join(conditions, " AND "), args, strings.Join(order, ", ")
+}
+
+// escapeLike escapes the wildcards of s with the backslash declared by the ESCAPE clause of Like.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,19 @@
+package requests
+
+import (
+	"context"
+	"net/http"
+)
+
+type httpRequestKey struct{}
+
+// WithHTTPRequest stores the incoming request, so actions and middlewares can read its headers.
+func WithHTTPRequest(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, httpRequestKey{}, r)
+}
+
+// HTTPRequest returns the incoming request stored by the endpoint, nil outside of one.
+func HTTPRequest(ctx context.Context) *http.Request {
+	r, _ := ctx.Value(httpRequestKey{}).(*http.Request)
+	return r
+}
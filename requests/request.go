@@ -6,3 +6,12 @@ type Request interface {
 	Path() (string, bool)
 	String() string
 }
+
+// Param documents a request parameter in the route introspection output.
+type Param struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required,omitempty"`
+	Description string `json:"description,omitempty"`
+}
@@ -0,0 +1,359 @@
+package resty
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/porebric/logger"
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/middleware"
+	"github.com/porebric/resty/pagination"
+	"github.com/porebric/resty/responses"
+)
+
+type Operation string
+
+const (
+	OperationList   Operation = "list"
+	OperationGet    Operation = "get"
+	OperationCreate Operation = "create"
+	OperationUpdate Operation = "update"
+	OperationDelete Operation = "delete"
+)
+
+type ResourceID interface {
+	~string | ~int | ~int32 | ~int64 | ~uint | ~uint32 | ~uint64
+}
+
+// Store is the persistence behind a Resource. Get, Update and Delete return errors.ErrNotFound for unknown ids,
+// an *errors.Error answers with its own code.
+type Store[T any, ID ResourceID] interface {
+	List(ctx context.Context, p pagination.Params) ([]T, int64, error)
+	Get(ctx context.Context, id ID) (T, error)
+	Create(ctx context.Context, item T) (T, ID, error)
+	Update(ctx context.Context, id ID, item T) (T, error)
+	Delete(ctx context.Context, id ID) error
+}
+
+// ConditionalStore is implemented by stores that check If-Match in the same transaction as the write.
+// match reports whether the current item still has the expected ETag, the store returns
+// errors.ErrPreconditionFailed if it does not. For other stores If-Match is checked by a Get before the write,
+// so concurrent writers can both pass it.
+type ConditionalStore[T any, ID ResourceID] interface {
+	UpdateIf(ctx context.Context, id ID, item T, match func(current T) bool) (T, error)
+	DeleteIf(ctx context.Context, id ID, match func(current T) bool) error
+}
+
+type ResourceOptions[T any, ID ResourceID] struct {
+	// Name prefixes the route names, like "users.list".
+	Name       string
+	Tags       []string
+	Pagination pagination.Options
+	// Middlewares are added to the endpoint of the operation.
+	Middlewares map[Operation][]func() middleware.Middleware
+
+	// List, Get, Create, Update and Delete replace the default action of the operation.
+	List   func(context.Context, *ListRequest) (responses.Response, int)
+	Get    func(context.Context, *ItemRequest[ID]) (responses.Response, int)
+	Create func(context.Context, *BodyRequest[T, ID]) (responses.Response, int)
+	Update func(context.Context, *BodyRequest[T, ID]) (responses.Response, int)
+	Delete func(context.Context, *ItemRequest[ID]) (responses.Response, int)
+}
+
+// Resource registers list, get, create, update and delete endpoints for store on path and path/{id}.
+// Bodies are validated with the `validate` tags of T, items carry an ETag and If-None-Match / If-Match are honoured.
+func Resource[T any, ID ResourceID](r Router, path string, store Store[T, ID], opts ResourceOptions[T, ID]) {
+	path = "/" + strings.Trim(path, "/")
+	itemPath := path + "/{id}"
+
+	if opts.List == nil {
+		opts.List = func(ctx context.Context, req *ListRequest) (responses.Response, int) {
+			items, total, err := store.List(ctx, req.Page)
+			if err != nil {
+				return storeError(ctx, err, errors.ErrorUnableGetData)
+			}
+			return pagination.NewOffsetPage(req.Page, items, total), http.StatusOK
+		}
+	}
+	if opts.Get == nil {
+		opts.Get = func(ctx context.Context, req *ItemRequest[ID]) (responses.Response, int) {
+			item, err := store.Get(ctx, req.ID)
+			if err != nil {
+				return storeError(ctx, err, errors.ErrorUnableGetData)
+			}
+
+			etag := resourceETag(item)
+			if etagMatch(req.IfNoneMatch, etag) {
+				resp := &responses.NoContent{Head: responses.Head{Status: http.StatusNotModified}}
+				resp.SetHeader("ETag", etag)
+				return resp, http.StatusNotModified
+			}
+
+			resp := &responses.JSON[T]{Data: item}
+			resp.SetHeader("ETag", etag)
+			return resp, http.StatusOK
+		}
+	}
+	if opts.Create == nil {
+		opts.Create = func(ctx context.Context, req *BodyRequest[T, ID]) (responses.Response, int) {
+			item, id, err := store.Create(ctx, req.Item)
+			if err != nil {
+				return storeError(ctx, err, errors.ErrorUnableAddData)
+			}
+
+			resp := &responses.Created[T]{Data: item, Location: fmt.Sprintf("%s/%v", path, id)}
+			resp.SetHeader("ETag", resourceETag(item))
+			return resp, http.StatusCreated
+		}
+	}
+	if opts.Update == nil {
+		opts.Update = func(ctx context.Context, req *BodyRequest[T, ID]) (responses.Response, int) {
+			var (
+				item T
+				err  error
+			)
+			if cs, ok := store.(ConditionalStore[T, ID]); ok && req.IfMatch != "" {
+				item, err = cs.UpdateIf(ctx, req.ID, req.Item, ifMatch[T](req.IfMatch))
+			} else {
+				if resp, code := checkIfMatch(ctx, store, req.ID, req.IfMatch); resp != nil {
+					return resp, code
+				}
+				item, err = store.Update(ctx, req.ID, req.Item)
+			}
+			if err != nil {
+				return storeError(ctx, err, errors.ErrorUnableAddData)
+			}
+
+			resp := &responses.JSON[T]{Data: item}
+			resp.SetHeader("ETag", resourceETag(item))
+			return resp, http.StatusOK
+		}
+	}
+	if opts.Delete == nil {
+		opts.Delete = func(ctx context.Context, req *ItemRequest[ID]) (responses.Response, int) {
+			var err error
+			if cs, ok := store.(ConditionalStore[T, ID]); ok && req.IfMatch != "" {
+				err = cs.DeleteIf(ctx, req.ID, ifMatch[T](req.IfMatch))
+			} else {
+				if resp, code := checkIfMatch(ctx, store, req.ID, req.IfMatch); resp != nil {
+					return resp, code
+				}
+				err = store.Delete(ctx, req.ID)
+			}
+			if err != nil {
+				return storeError(ctx, err, errors.ErrorUnableDeleteData)
+			}
+			return &responses.NoContent{}, http.StatusNoContent
+		}
+	}
+
+	spec := func(op Operation, path, method string) RouteSpec {
+		s := RouteSpec{Path: path, Methods: []string{method}, Tags: opts.Tags}
+		if opts.Name != "" {
+			s.Name = fmt.Sprintf("%s.%s", opts.Name, op)
+		}
+		return s
+	}
+
+	listSpec := spec(OperationList, path, http.MethodGet)
+	listSpec.Params = pagination.Parameters(opts.Pagination)
+
+	EndpointWithSpec(r, listSpec, func(ctx context.Context, r *http.Request) (context.Context, *ListRequest, error) {
+		page, err := pagination.Bind(r, opts.Pagination)
+		return ctx, &ListRequest{Page: page}, err
+	}, opts.List, opts.Middlewares[OperationList]...)
+
+	EndpointWithSpec(r, spec(OperationGet, itemPath, http.MethodGet), initItemRequest[ID], opts.Get, opts.Middlewares[OperationGet]...)
+	EndpointWithSpec(r, spec(OperationCreate, path, http.MethodPost), initBodyRequest[T, ID](false), opts.Create, opts.Middlewares[OperationCreate]...)
+	EndpointWithSpec(r, spec(OperationUpdate, itemPath, http.MethodPut), initBodyRequest[T, ID](true), opts.Update, opts.Middlewares[OperationUpdate]...)
+	EndpointWithSpec(r, spec(OperationDelete, itemPath, http.MethodDelete), initItemRequest[ID], opts.Delete, opts.Middlewares[OperationDelete]...)
+}
+
+type ListRequest struct {
+	Page pagination.Params
+}
+
+func (r *ListRequest) Validate() (bool, string, string) {
+	return true, "", ""
+}
+
+func (r *ListRequest) Methods() []string {
+	return []string{http.MethodGet}
+}
+
+func (r *ListRequest) Path() (string, bool) {
+	return "", false
+}
+
+func (r *ListRequest) String() string {
+	return fmt.Sprintf(`{"limit":%d,"offset":%d,"cursor":%q}`, r.Page.Limit, r.Page.Offset, r.Page.Cursor)
+}
+
+type ItemRequest[ID ResourceID] struct {
+	ID          ID
+	IfMatch     string
+	IfNoneMatch string
+}
+
+func (r *ItemRequest[ID]) Validate() (bool, string, string) {
+	return true, "", ""
+}
+
+func (r *ItemRequest[ID]) Methods() []string {
+	return []string{http.MethodGet, http.MethodDelete}
+}
+
+func (r *ItemRequest[ID]) Path() (string, bool) {
+	return "", false
+}
+
+func (r *ItemRequest[ID]) String() string {
+	return fmt.Sprintf(`{"id":%q}`, fmt.Sprint(r.ID))
+}
+
+// BodyRequest carries the decoded body of create and update requests. Item is validated with its `validate` tags.
+type BodyRequest[T any, ID ResourceID] struct {
+	ID      ID     `json:"-"`
+	IfMatch string `json:"-"`
+	Item    T      `json:"item" validate:"inline"`
+}
+
+func (r *BodyRequest[T, ID]) Validate() (bool, string, string) {
+	return true, "", ""
+}
+
+func (r *BodyRequest[T, ID]) Methods() []string {
+	return []string{http.MethodPost, http.MethodPut}
+}
+
+func (r *BodyRequest[T, ID]) Path() (string, bool) {
+	return "", false
+}
+
+func (r *BodyRequest[T, ID]) String() string {
+	body, _ := json.Marshal(r.Item)
+	return string(body)
+}
+
+func initItemRequest[ID ResourceID](ctx context.Context, r *http.Request) (context.Context, *ItemRequest[ID], error) {
+	req := &ItemRequest[ID]{IfMatch: r.Header.Get("If-Match"), IfNoneMatch: r.Header.Get("If-None-Match")}
+
+	id, err := parseID[ID](mux.Vars(r)["id"])
+	if err != nil {
+		return ctx, req, err
+	}
+	req.ID = id
+
+	return ctx, req, nil
+}
+
+func initBodyRequest[T any, ID ResourceID](withID bool) func(ctx context.Context, r *http.Request) (context.Context, *BodyRequest[T, ID], error) {
+	return func(ctx context.Context, r *http.Request) (context.Context, *BodyRequest[T, ID], error) {
+		req := &BodyRequest[T, ID]{IfMatch: r.Header.Get("If-Match")}
+
+		if withID {
+			id, err := parseID[ID](mux.Vars(r)["id"])
+			if err != nil {
+				return ctx, req, err
+			}
+			req.ID = id
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req.Item); err != nil {
+			return ctx, req, errors.New(errors.ErrorInvalidRequest, "invalid body")
+		}
+
+		return ctx, req, nil
+	}
+}
+
+// parseID parses the whole path segment, so "12abc" is rejected instead of read as 12.
+func parseID[ID ResourceID](s string) (ID, error) {
+	var id ID
+
+	v := reflect.ValueOf(&id).Elem()
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return id, nil
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return id, errors.New(errors.ErrorInvalidRequest, "id: invalid")
+		}
+		v.SetInt(n)
+	default:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return id, errors.New(errors.ErrorInvalidRequest, "id: invalid")
+		}
+		v.SetUint(n)
+	}
+	return id, nil
+}
+
+func ifMatch[T any](header string) func(current T) bool {
+	return func(current T) bool {
+		return etagMatch(header, resourceETag(current))
+	}
+}
+
+// checkIfMatch answers 412 if the If-Match header does not match the current item. It is advisory,
+// a write between this Get and the write of the caller is not detected.
+func checkIfMatch[T any, ID ResourceID](ctx context.Context, store Store[T, ID], id ID, ifMatch string) (responses.Response, int) {
+	if ifMatch == "" {
+		return nil, 0
+	}
+
+	item, err := store.Get(ctx, id)
+	if err != nil {
+		return storeError(ctx, err, errors.ErrorUnableGetData)
+	}
+
+	if !etagMatch(ifMatch, resourceETag(item)) {
+		return errors.GetCustomError("", errors.ErrorPreconditionFailed)
+	}
+	return nil, 0
+}
+
+// storeError maps a store error to an error response, unknown errors are logged and answered with code.
+func storeError(ctx context.Context, err error, code int32) (responses.Response, int) {
+	var reqErr *errors.Error
+	switch {
+	case stderrors.Is(err, errors.ErrNotFound):
+		return errors.GetCustomError("", errors.ErrorNotFound)
+	case stderrors.Is(err, errors.ErrPreconditionFailed):
+		return errors.GetCustomError("", errors.ErrorPreconditionFailed)
+	case stderrors.As(err, &reqErr):
+		return errors.GetCustomError(reqErr.Message, reqErr.Code)
+	default:
+		logger.Error(ctx, err, "resource store")
+		return errors.GetCustomError("", code)
+	}
+}
+
+func resourceETag(item any) string {
+	body, _ := json.Marshal(item)
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatch reports whether a If-Match or If-None-Match header value matches etag.
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package resty
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+)
+
+// maxBufferedResponse is the amount of body kept in memory before the response is committed to the client.
+// While the body is buffered an encode error or a panic can still be turned into a proper error response.
+const maxBufferedResponse = 64 << 10
+
+type responseWriter struct {
+	w http.ResponseWriter
+
+	status      int
+	written     int64
+	wroteHeader bool
+	committed   bool
+	noBody      bool
+
+	buf bytes.Buffer
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{w: w}
+}
+
+// discardBody makes the writer drop the body while still counting it, as required for HEAD requests.
+func (rw *responseWriter) discardBody() {
+	rw.noBody = true
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.w.Header()
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+
+	rw.wroteHeader = true
+	rw.status = code
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if rw.committed {
+		if rw.noBody {
+			rw.written += int64(len(b))
+			return len(b), nil
+		}
+
+		n, err := rw.w.Write(b)
+		rw.written += int64(n)
+		return n, err
+	}
+
+	if rw.buf.Len()+len(b) <= maxBufferedResponse {
+		n, _ := rw.buf.Write(b)
+		rw.written += int64(n)
+		return n, nil
+	}
+
+	if err := rw.commit(); err != nil {
+		return 0, err
+	}
+
+	n, err := rw.w.Write(b)
+	rw.written += int64(n)
+	return n, err
+}
+
+// Flush commits the buffered response and flushes the underlying writer.
+func (rw *responseWriter) Flush() {
+	if err := rw.commit(); err != nil {
+		return
+	}
+
+	if f, ok := rw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.w
+}
+
+// Committed reports whether the status line has already been sent to the client.
+func (rw *responseWriter) Committed() bool {
+	return rw.committed
+}
+
+// Status returns the status code of the response, http.StatusOK if the handler did not set one.
+func (rw *responseWriter) Status() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+// Size returns the number of body bytes written by the handler.
+func (rw *responseWriter) Size() int64 {
+	return rw.written
+}
+
+// representationHeaders describe the body written by the handler and are dropped with it by reset.
+var representationHeaders = []string{
+	"Content-Length", "Content-Encoding", "Content-Disposition", "Content-Range", "Content-Language",
+	"ETag", "Last-Modified",
+}
+
+// reset drops the buffered status, body and the headers describing it. It reports false if the response
+// is already committed.
+func (rw *responseWriter) reset() bool {
+	if rw.committed {
+		return false
+	}
+
+	for _, h := range representationHeaders {
+		rw.Header().Del(h)
+	}
+	rw.buf.Reset()
+	rw.status = 0
+	rw.written = 0
+	rw.wroteHeader = false
+	return true
+}
+
+// finish commits the response once the handler is done. A fully buffered body gets its Content-Length.
+func (rw *responseWriter) finish() error {
+	if !rw.committed && rw.Header().Get("Content-Length") == "" && rw.status != http.StatusNoContent && rw.status != http.StatusNotModified {
+		rw.Header().Set("Content-Length", strconv.Itoa(rw.buf.Len()))
+	}
+
+	return rw.commit()
+}
+
+func (rw *responseWriter) commit() error {
+	if rw.committed {
+		return nil
+	}
+	rw.committed = true
+
+	rw.w.WriteHeader(rw.Status())
+	if rw.buf.Len() == 0 || rw.noBody {
+		rw.buf.Reset()
+		return nil
+	}
+
+	_, err := rw.w.Write(rw.buf.Bytes())
+	rw.buf.Reset()
+	return err
+}
@@ -0,0 +1,62 @@
+package resty
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/responses"
+)
+
+// failingDownload sets the headers of a file and fails before writing it.
+type failingDownload struct{}
+
+func (failingDownload) PrepareResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Length", "1048576")
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="report.csv"`)
+	w.Header().Set("ETag", `"v1"`)
+	_, _ = w.Write([]byte("partial"))
+	return stderrors.New("storage unavailable")
+}
+
+func (failingDownload) String() string { return "" }
+
+func TestFailedResponseDropsRepresentationHeaders(t *testing.T) {
+	r := newTestRouter()
+	EndpointWithSpec(r, RouteSpec{Path: "/report", Methods: []string{http.MethodGet}},
+		func(ctx context.Context, _ *http.Request) (context.Context, *echoRequest, error) {
+			return ctx, new(echoRequest), nil
+		},
+		func(context.Context, *echoRequest) (responses.Response, int) {
+			return failingDownload{}, http.StatusOK
+		})
+
+	w := httptest.NewRecorder()
+	r.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/report", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	for _, h := range []string{"Content-Encoding", "Content-Disposition", "ETag"} {
+		if v := w.Header().Get(h); v != "" {
+			t.Errorf("%s = %q, want none", h, v)
+		}
+	}
+	if got, want := w.Header().Get("Content-Length"), len(w.Body.Bytes()); got != strconv.Itoa(want) {
+		t.Errorf("Content-Length = %s, body is %d bytes", got, want)
+	}
+
+	var resp responses.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	if resp.Code != errors.ErrorCritical {
+		t.Fatalf("code = %d, want ErrorCritical", resp.Code)
+	}
+}
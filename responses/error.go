@@ -3,11 +3,14 @@ package responses
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/porebric/resty/validation"
 )
 
 type ErrorResponse struct {
-	Code    int32  `json:"code"`
-	Message string `json:"message"`
+	Code    int32                   `json:"code"`
+	Message string                  `json:"message"`
+	Fields  []validation.FieldError `json:"fields,omitempty"`
 }
 
 func (r *ErrorResponse) PrepareResponse(w http.ResponseWriter) error {
@@ -0,0 +1,125 @@
+package responses
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Content streams an io.ReadSeeker. Range, If-Range and conditional requests are answered with
+// 206, multipart/byteranges, 304 or 412 as appropriate.
+type Content struct {
+	Name        string
+	ContentType string
+	ModTime     time.Time
+	ETag        string
+	Attachment  bool
+	Content     io.ReadSeeker
+}
+
+func (r *Content) ServeResponse(w http.ResponseWriter, req *http.Request) error {
+	r.setHeaders(w)
+	http.ServeContent(w, req, r.Name, r.ModTime, r.Content)
+	return nil
+}
+
+// PrepareResponse writes the whole content, it is used when there is no request to honour ranges.
+func (r *Content) PrepareResponse(w http.ResponseWriter) error {
+	r.setHeaders(w)
+	if !r.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", r.ModTime.UTC().Format(http.TimeFormat))
+	}
+
+	if _, err := r.Content.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, r.Content)
+	return err
+}
+
+func (r *Content) String() string {
+	body, _ := json.Marshal(map[string]string{"file": r.Name, "content_type": r.ContentType})
+	return string(body)
+}
+
+func (r *Content) setHeaders(w http.ResponseWriter) {
+	contentType := r.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(r.Name))
+	}
+
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	} else {
+		// ServeContent sniffs the type when the header is missing.
+		w.Header().Del("Content-Type")
+	}
+
+	if r.ETag != "" {
+		w.Header().Set("ETag", r.ETag)
+	}
+
+	disposition := "inline"
+	if r.Attachment {
+		disposition = "attachment"
+	}
+	if r.Name != "" {
+		disposition = mime.FormatMediaType(disposition, map[string]string{"filename": filepath.Base(r.Name)})
+	}
+	w.Header().Set("Content-Disposition", disposition)
+}
+
+// File streams a file from disk. Name defaults to the base name of Path.
+type File struct {
+	Path        string
+	Name        string
+	ContentType string
+	Attachment  bool
+}
+
+func (r *File) ServeResponse(w http.ResponseWriter, req *http.Request) error {
+	return r.serve(w, func(c *Content) error { return c.ServeResponse(w, req) })
+}
+
+func (r *File) PrepareResponse(w http.ResponseWriter) error {
+	return r.serve(w, func(c *Content) error { return c.PrepareResponse(w) })
+}
+
+func (r *File) String() string {
+	body, _ := json.Marshal(map[string]string{"file": r.Path, "content_type": r.ContentType})
+	return string(body)
+}
+
+func (r *File) serve(w http.ResponseWriter, fn func(c *Content) error) error {
+	f, err := os.Open(r.Path)
+	if os.IsNotExist(err) {
+		w.WriteHeader(http.StatusNotFound)
+		return json.NewEncoder(w).Encode(&ErrorResponse{Message: "not found"})
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	name := r.Name
+	if name == "" {
+		name = filepath.Base(r.Path)
+	}
+
+	return fn(&Content{
+		Name:        name,
+		ContentType: r.ContentType,
+		ModTime:     info.ModTime(),
+		Attachment:  r.Attachment,
+		Content:     f,
+	})
+}
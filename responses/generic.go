@@ -0,0 +1,161 @@
+package responses
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Head carries the status code, headers and cookies of a response. Responses embedding it don't need the
+// action to return a separate status code: a non-zero Status wins over the returned one.
+type Head struct {
+	Status  int
+	Header  http.Header
+	Cookies []*http.Cookie
+}
+
+// Headed is implemented by responses embedding Head.
+type Headed interface {
+	ResponseHead() *Head
+}
+
+func (h *Head) ResponseHead() *Head {
+	return h
+}
+
+func (h *Head) SetHeader(key, value string) {
+	if h.Header == nil {
+		h.Header = make(http.Header)
+	}
+	h.Header.Set(key, value)
+}
+
+// DelHeader removes a header already set on the response, like the default Content-Type.
+func (h *Head) DelHeader(key string) {
+	if h.Header == nil {
+		h.Header = make(http.Header)
+	}
+	h.Header[http.CanonicalHeaderKey(key)] = nil
+}
+
+func (h *Head) AddCookie(c *http.Cookie) {
+	h.Cookies = append(h.Cookies, c)
+}
+
+// Apply copies the headers and cookies to w. A header set to nil is removed.
+func (h *Head) Apply(w http.ResponseWriter) {
+	for key, values := range h.Header {
+		if values == nil {
+			w.Header().Del(key)
+			continue
+		}
+		w.Header()[key] = values
+	}
+
+	for _, c := range h.Cookies {
+		http.SetCookie(w, c)
+	}
+}
+
+// JSON encodes Data as the response body.
+type JSON[T any] struct {
+	Head
+	Data T
+}
+
+func (r *JSON[T]) PrepareResponse(w http.ResponseWriter) error {
+	return json.NewEncoder(w).Encode(r.Data)
+}
+
+func (r *JSON[T]) String() string {
+	body, _ := json.Marshal(r.Data)
+	return string(body)
+}
+
+// Created answers 201 with Data and a Location header pointing to the new resource.
+type Created[T any] struct {
+	Head
+	Data     T
+	Location string
+}
+
+func (r *Created[T]) ResponseHead() *Head {
+	if r.Status == 0 {
+		r.Status = http.StatusCreated
+	}
+	if r.Location != "" {
+		r.SetHeader("Location", r.Location)
+	}
+	return &r.Head
+}
+
+func (r *Created[T]) PrepareResponse(w http.ResponseWriter) error {
+	return json.NewEncoder(w).Encode(r.Data)
+}
+
+func (r *Created[T]) String() string {
+	body, _ := json.Marshal(r.Data)
+	return string(body)
+}
+
+// NoContent answers 204 without a body.
+type NoContent struct {
+	Head
+}
+
+func (r *NoContent) ResponseHead() *Head {
+	if r.Status == 0 {
+		r.Status = http.StatusNoContent
+	}
+	r.DelHeader("Content-Type")
+	return &r.Head
+}
+
+func (r *NoContent) PrepareResponse(http.ResponseWriter) error {
+	return nil
+}
+
+func (r *NoContent) String() string {
+	return ""
+}
+
+// Redirect answers 302, or Status if set, with a Location header and no body.
+type Redirect struct {
+	Head
+	URL string
+}
+
+func (r *Redirect) ResponseHead() *Head {
+	if r.Status == 0 {
+		r.Status = http.StatusFound
+	}
+	r.SetHeader("Location", r.URL)
+	r.DelHeader("Content-Type")
+	return &r.Head
+}
+
+func (r *Redirect) PrepareResponse(http.ResponseWriter) error {
+	return nil
+}
+
+func (r *Redirect) String() string {
+	body, _ := json.Marshal(map[string]string{"location": r.URL})
+	return string(body)
+}
+
+// Envelope wraps Data into the standard {"data", "meta", "errors", "warnings"} body.
+type Envelope[T any] struct {
+	Head     `json:"-"`
+	Data     T               `json:"data"`
+	Meta     any             `json:"meta,omitempty"`
+	Errors   []ErrorResponse `json:"errors,omitempty"`
+	Warnings []string        `json:"warnings,omitempty"`
+}
+
+func (r *Envelope[T]) PrepareResponse(w http.ResponseWriter) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+func (r *Envelope[T]) String() string {
+	body, _ := json.Marshal(r)
+	return string(body)
+}
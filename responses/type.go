@@ -6,3 +6,10 @@ type Response interface {
 	PrepareResponse(w http.ResponseWriter) error
 	String() string
 }
+
+// RequestResponse is implemented by responses that depend on the incoming request, like conditional and
+// range requests. ServeResponse is called instead of PrepareResponse and sets the status code itself.
+type RequestResponse interface {
+	Response
+	ServeResponse(w http.ResponseWriter, r *http.Request) error
+}
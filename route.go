@@ -0,0 +1,186 @@
+package resty
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+
+	"github.com/porebric/resty/middleware"
+	"github.com/porebric/resty/requests"
+)
+
+// RouteSpec describes how an endpoint is exposed. Empty Path and Methods fall back to the values
+// returned by the request type, so existing requests keep working without a spec.
+type RouteSpec struct {
+	Path string
+	// ShowPath logs the actual request path instead of the path template.
+	ShowPath    bool
+	Methods     []string
+	Name        string
+	Group       string
+	Version     string
+	Tags        []string
+	Description string
+	Params      []requests.Param
+}
+
+// RouteInfo describes a registered endpoint, as returned by Router.Routes.
+type RouteInfo struct {
+	Path        string           `json:"path"`
+	Methods     []string         `json:"methods"`
+	Name        string           `json:"name,omitempty"`
+	Request     string           `json:"request"`
+	Middlewares []string         `json:"middlewares,omitempty"`
+	Group       string           `json:"group,omitempty"`
+	Version     string           `json:"version,omitempty"`
+	Tags        []string         `json:"tags,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Params      []requests.Param `json:"params,omitempty"`
+	// Authorization lists the policies checked for the endpoint.
+	Authorization string `json:"authorization,omitempty"`
+}
+
+var pathVarRegexp = regexp.MustCompile(`\{[^{}:]+(:[^{}]*)?}`)
+
+// resolveSpec fills the empty parts of spec from a zero value of R.
+func resolveSpec[R requests.Request](spec RouteSpec) RouteSpec {
+	if spec.Path != "" && len(spec.Methods) != 0 {
+		return spec
+	}
+
+	req := newRequest[R]()
+	if spec.Path == "" {
+		spec.Path, spec.ShowPath = req.Path()
+	}
+	if len(spec.Methods) == 0 {
+		spec.Methods = req.Methods()
+	}
+
+	return spec
+}
+
+// newRequest returns the zero value of R, or a pointer to a zero struct when R is a pointer type,
+// so that methods reading fields do not dereference nil.
+func newRequest[R requests.Request]() R {
+	var req R
+
+	t := reflect.TypeOf(&req).Elem()
+	if t.Kind() == reflect.Pointer {
+		req = reflect.New(t.Elem()).Interface().(R)
+	}
+
+	return req
+}
+
+// middlewareNames returns the type names of the middlewares in the order they are executed.
+func middlewareNames(mm []func() middleware.Middleware) []string {
+	names := make([]string, 0, len(mm)+1)
+	names = append(names, typeName(new(middleware.RequestValidate)))
+	for _, m := range mm {
+		names = append(names, typeName(m()))
+	}
+	return names
+}
+
+func typeName(v any) string {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return ""
+	}
+	return t.String()
+}
+
+// safeString returns s.String(), or an empty string if it panics.
+func safeString(s fmt.Stringer) (str string) {
+	defer func() {
+		if recover() != nil {
+			str = ""
+		}
+	}()
+
+	if s == nil || reflect.ValueOf(s).Kind() == reflect.Pointer && reflect.ValueOf(s).IsNil() {
+		return ""
+	}
+
+	return s.String()
+}
+
+// Routes returns every endpoint registered on the router in registration order.
+func (r *router) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(r.endpoints))
+	for _, e := range r.endpoints {
+		routes = append(routes, RouteInfo{
+			Path:        e.spec.Path,
+			Methods:     slices.Clone(e.spec.Methods),
+			Name:        e.spec.Name,
+			Request:     e.request,
+			Middlewares: slices.Clone(e.middlewares),
+			Group:       e.spec.Group,
+			Version:     e.spec.Version,
+			Tags:        slices.Clone(e.spec.Tags),
+			Description: e.spec.Description,
+			Params:      slices.Clone(e.spec.Params),
+
+			Authorization: r.describePolicies(e),
+		})
+	}
+	return routes
+}
+
+// EnableRoutesDebug serves Routes as JSON on path, /debug/routes if path is empty.
+func (r *router) EnableRoutesDebug(path string) {
+	if path == "" {
+		path = "/debug/routes"
+	}
+
+	r.router.HandleFunc(path, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(r.Routes())
+	}).Methods(http.MethodGet)
+}
+
+// validate checks that no two endpoints serve the same method on the same path and that route names are unique.
+func (r *router) validate() error {
+	names := make(map[string]string)
+
+	for i, e := range r.endpoints {
+		if e.spec.Name != "" {
+			if path, ok := names[e.spec.Name]; ok {
+				return fmt.Errorf("route name %q is used by %s and %s", e.spec.Name, path, e.spec.Path)
+			}
+			names[e.spec.Name] = e.spec.Path
+		}
+
+		if len(e.spec.Methods) == 0 {
+			return fmt.Errorf("route %s has no methods", e.spec.Path)
+		}
+
+		for _, other := range r.endpoints[:i] {
+			if normalizePath(other.spec.Path) != normalizePath(e.spec.Path) {
+				continue
+			}
+
+			for _, method := range e.spec.Methods {
+				if slices.Contains(other.spec.Methods, method) {
+					return fmt.Errorf("route %s %s conflicts with %s %s", method, e.spec.Path, method, other.spec.Path)
+				}
+				if method == http.MethodHead && slices.Contains(other.spec.Methods, http.MethodGet) {
+					return fmt.Errorf("route HEAD %s conflicts with implicit HEAD of GET %s", e.spec.Path, other.spec.Path)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// normalizePath drops variable names from a path template, so /users/{id} and /users/{uid} compare equal.
+func normalizePath(path string) string {
+	return pathVarRegexp.ReplaceAllString(path, "{$1}")
+}
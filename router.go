@@ -3,11 +3,16 @@ package resty
 import (
 	"context"
 	"encoding/json"
+	"io/fs"
 	"net/http"
 	"net/http/pprof"
+	"slices"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/porebric/logger"
+	"github.com/porebric/resty/authz"
+	"github.com/porebric/resty/closer"
 	"github.com/porebric/resty/responses"
 	"github.com/porebric/resty/ws"
 	"github.com/prometheus/client_golang/prometheus"
@@ -23,6 +28,19 @@ type Router interface {
 	CorsAllowedOrigins() []string
 	CorsAllowedMethods() []string
 	CorsAllowedHeaders() []string
+
+	SetMethodNotAllowedHandler(h http.Handler)
+
+	Routes() []RouteInfo
+	EnableRoutesDebug(path string)
+
+	ServeStatic(prefix string, fsys fs.FS, opts StaticOptions)
+	EnableBatch(path string, opts BatchOptions)
+
+	OnShutdown(fn closer.Func)
+
+	Authorize(group string, p authz.Policy)
+	DenyByDefault()
 }
 
 type router struct {
@@ -33,6 +51,24 @@ type router struct {
 	corsAllowedOrigins []string
 	corsAllowedMethods []string
 	corsAllowedHeaders []string
+
+	endpoints        []*endpoint
+	methodNotAllowed http.Handler
+	shutdown         []closer.Func
+
+	groupPolicies map[string]authz.Policy
+	denyByDefault bool
+}
+
+type endpoint struct {
+	spec        RouteSpec
+	request     string
+	middlewares []string
+	route       *mux.Route
+
+	// policies are the authz policies of the endpoint, checksPolicies is set for endpoints running routeAuthorization.
+	policies       []authz.Policy
+	checksPolicies bool
 }
 
 func NewRouter(logFn func() *logger.Logger, wsHub *ws.Hub) Router {
@@ -44,7 +80,7 @@ func NewRouter(logFn func() *logger.Logger, wsHub *ws.Hub) Router {
 	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
 
-	prometheus.MustRegister(requestCounter)
+	prometheus.MustRegister(requestCounter, responseSize)
 
 	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		logger.Warn(logger.ToContext(context.Background(), logFn()), "not found", "method", r.Method, "path", r.URL.Path)
@@ -56,11 +92,21 @@ func NewRouter(logFn func() *logger.Logger, wsHub *ws.Hub) Router {
 
 	r.Handle("/metrics", promhttp.Handler())
 
-	return &router{
+	rr := &router{
 		router: r,
 		logFn:  logFn,
 		wsHub:  wsHub,
 	}
+
+	rr.methodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Warn(logger.ToContext(context.Background(), logFn()), "method not allowed", "method", r.Method, "path", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_ = json.NewEncoder(w).Encode(&responses.ErrorResponse{Message: "method not allowed"})
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(rr.serveMethodNotAllowed)
+
+	return rr
 }
 
 func (r *router) CorsAllowedOrigins() []string {
@@ -92,3 +138,86 @@ func (r *router) SetCors(allowedOrigins, allowedMethods, allowedHeaders []string
 func (r *router) GetWsHub() *ws.Hub {
 	return r.wsHub
 }
+
+// SetMethodNotAllowedHandler replaces the 405 response. The Allow header is already set when h is called.
+func (r *router) SetMethodNotAllowedHandler(h http.Handler) {
+	r.methodNotAllowed = h
+}
+
+// OnShutdown registers fn to be called by RunServer before the closers passed to it,
+// so components like worker pools drain while their dependencies are still open.
+func (r *router) OnShutdown(fn closer.Func) {
+	r.shutdown = append(r.shutdown, fn)
+}
+
+// asRouter returns the router of the package behind r, nil for Router implementations from outside it.
+func asRouter(r Router) *router {
+	rr, _ := r.(*router)
+	return rr
+}
+
+// handle registers h for the path and methods of e. GET routes also answer HEAD.
+// Routers implemented outside the package only get the mux route.
+func handle(r Router, e *endpoint, h http.HandlerFunc) {
+	methods := e.spec.Methods
+	if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+		methods = append(slices.Clone(methods), http.MethodHead)
+	}
+
+	e.route = r.MuxRouter().HandleFunc(e.spec.Path, h).Methods(methods...)
+	if e.spec.Name != "" {
+		e.route.Name(e.spec.Name)
+	}
+
+	if rr := asRouter(r); rr != nil {
+		rr.endpoints = append(rr.endpoints, e)
+	}
+}
+
+// serveMethodNotAllowed answers requests whose path is registered with another method. OPTIONS is answered
+// here with the methods of all endpoints on the path, unless an endpoint declares OPTIONS itself.
+func (r *router) serveMethodNotAllowed(w http.ResponseWriter, req *http.Request) {
+	var allow []string
+	for _, e := range r.endpoints {
+		var match mux.RouteMatch
+		if e.route.Match(req, &match) || match.MatchErr == mux.ErrMethodMismatch {
+			allow = appendUnique(allow, r.allowed(e.spec.Path)...)
+		}
+	}
+
+	if len(allow) != 0 {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+
+		if req.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	r.methodNotAllowed.ServeHTTP(w, req)
+}
+
+// allowed returns the methods served on path in registration order.
+func (r *router) allowed(path string) []string {
+	var allow []string
+	for _, e := range r.endpoints {
+		if e.spec.Path != path {
+			continue
+		}
+
+		allow = appendUnique(allow, e.spec.Methods...)
+		if slices.Contains(e.spec.Methods, http.MethodGet) {
+			allow = appendUnique(allow, http.MethodHead)
+		}
+	}
+
+	return appendUnique(allow, http.MethodOptions)
+}
+
+func appendUnique(dst []string, values ...string) []string {
+	for _, v := range values {
+		if !slices.Contains(dst, v) {
+			dst = append(dst, v)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,313 @@
+package resty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/porebric/logger"
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/middleware"
+	"github.com/porebric/resty/requests"
+	"github.com/porebric/resty/responses"
+	"github.com/porebric/resty/validation"
+	"github.com/porebric/tracer"
+)
+
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+	// RPCServerError is the base of codes mapped from errors.CustomErrorMap: RPCServerError - resty code.
+	// Resty codes outside 0..99 would leave the reserved range, they are answered with RPCServerError
+	// and found in the code field of the error data.
+	RPCServerError = -32000
+)
+
+// RPCGroup is the group of every JSON-RPC method, Router.Authorize(RPCGroup, p) requires p for all of them.
+const RPCGroup = "rpc"
+
+// RPCServer exposes typed actions as JSON-RPC 2.0 methods on a single route.
+type RPCServer struct {
+	router Router
+	logFn  func() *logger.Logger
+
+	mu      sync.RWMutex
+	methods map[string]*rpcMethod
+}
+
+type rpcMethod struct {
+	info RPCMethodInfo
+	call func(ctx context.Context, params json.RawMessage) (json.RawMessage, *RPCError)
+}
+
+// RPCMethodInfo is returned by the rpc.discover method.
+type RPCMethodInfo struct {
+	Name        string   `json:"name"`
+	Request     string   `json:"request"`
+	Middlewares []string `json:"middlewares,omitempty"`
+}
+
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// NewRPC registers a JSON-RPC 2.0 route on path. Methods are added with RPCMethod, rpc.discover lists them.
+// rpc.discover is authorized like the methods: the policy of RPCGroup applies and deny-by-default routers
+// deny it until Discover opens it.
+func NewRPC(r Router, path string) *RPCServer {
+	s := &RPCServer{router: r, logFn: r.LogFn, methods: make(map[string]*rpcMethod)}
+
+	handle(r, &endpoint{
+		spec:           RouteSpec{Path: path, Methods: []string{http.MethodPost}, Group: RPCGroup, Description: "JSON-RPC 2.0"},
+		request:        "jsonrpc",
+		checksPolicies: true,
+	}, s.serveHTTP)
+
+	s.Discover()
+	return s
+}
+
+// Discover sets the middlewares of rpc.discover, like authz.Require(authz.Public()) to list the methods
+// to everyone.
+func (s *RPCServer) Discover(mm ...func() middleware.Middleware) {
+	addRPCMethod(s, "rpc.discover", func(context.Context, *rpcDiscoverRequest) (responses.Response, int) {
+		return &responses.JSON[[]RPCMethodInfo]{Data: s.Methods()}, http.StatusOK
+	}, mm...)
+}
+
+type rpcDiscoverRequest struct{}
+
+func (r *rpcDiscoverRequest) Validate() (bool, string, string) {
+	return true, "", ""
+}
+
+func (r *rpcDiscoverRequest) Methods() []string {
+	return []string{http.MethodPost}
+}
+
+func (r *rpcDiscoverRequest) Path() (string, bool) {
+	return "", false
+}
+
+func (r *rpcDiscoverRequest) String() string {
+	return "rpc.discover"
+}
+
+// RPCMethod exposes action as the JSON-RPC method name. params are decoded into R, which is validated and passed
+// through mm exactly like in Endpoint, including the policy of RPCGroup and the deny-by-default mode.
+// The rendered response becomes the result, error responses and status codes from 400 become error objects.
+func RPCMethod[R requests.Request](s *RPCServer, name string, action func(context.Context, R) (responses.Response, int), mm ...func() middleware.Middleware) {
+	if strings.HasPrefix(name, "rpc.") {
+		panic(fmt.Sprintf("resty: rpc method %s uses the reserved prefix", name))
+	}
+	if err := validation.Compile(newRequest[R]()); err != nil {
+		panic(fmt.Sprintf("resty: rpc method %s: %v", name, err))
+	}
+
+	addRPCMethod(s, name, action, mm...)
+}
+
+func addRPCMethod[R requests.Request](s *RPCServer, name string, action func(context.Context, R) (responses.Response, int), mm ...func() middleware.Middleware) {
+
+	info := RPCMethodInfo{Name: name, Request: typeName(newRequest[R]()), Middlewares: middlewareNames(mm)}
+	mm = append(slices.Clone(mm), routeAuthorization(s.router, RPCGroup, len(endpointPolicies(mm)) != 0))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.methods[name] = &rpcMethod{
+		info: info,
+		call: func(ctx context.Context, params json.RawMessage) (json.RawMessage, *RPCError) {
+			req := newRequest[R]()
+			if len(params) != 0 {
+				if err := json.Unmarshal(params, &req); err != nil {
+					return nil, &RPCError{Code: RPCInvalidParams, Message: "invalid params"}
+				}
+			}
+
+			ctx, errResp, httpCode := checkAction(ctx, req, mm...)
+			if httpCode != 0 {
+				return nil, rpcErrorFromResponse(errResp, httpCode)
+			}
+
+			resp, httpCode := action(ctx, req)
+			httpCode = responseStatus(resp, httpCode)
+			if errResp, ok := resp.(*responses.ErrorResponse); ok && httpCode >= http.StatusBadRequest {
+				return nil, rpcErrorFromResponse(errResp, httpCode)
+			}
+
+			result, err := responseBody(resp)
+			if err != nil {
+				logger.Error(ctx, err, "prepare rpc result")
+				return nil, &RPCError{Code: RPCInternalError, Message: "internal error"}
+			}
+			if len(result) == 0 {
+				result = json.RawMessage("null")
+			}
+			if httpCode >= http.StatusBadRequest {
+				return nil, &RPCError{Code: RPCServerError, Message: http.StatusText(httpCode), Data: json.RawMessage(result)}
+			}
+			return result, nil
+		},
+	}
+}
+
+// rpcErrorFromResponse maps a resty error to a JSON-RPC error object, keeping the original code and status in data.
+func rpcErrorFromResponse(resp *responses.ErrorResponse, httpCode int) *RPCError {
+	code := RPCServerError
+	if resp.Code >= 0 && resp.Code <= 99 {
+		code -= int(resp.Code)
+	}
+
+	switch resp.Code {
+	case errors.ErrorInvalidRequest:
+		code = RPCInvalidParams
+	case errors.ErrorCritical:
+		code = RPCInternalError
+	}
+
+	data := map[string]any{"code": resp.Code, "http_status": httpCode}
+	if len(resp.Fields) != 0 {
+		data["fields"] = resp.Fields
+	}
+
+	return &RPCError{Code: code, Message: resp.Message, Data: data}
+}
+
+func (s *RPCServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ctx := requests.WithHTTPRequest(r.Context(), r)
+
+	var body json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		_ = json.NewEncoder(w).Encode(rpcFailure(nil, RPCParseError, "parse error"))
+		return
+	}
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) != 0 && trimmed[0] == '[' {
+		var calls []json.RawMessage
+		if err := json.Unmarshal(trimmed, &calls); err != nil || len(calls) == 0 {
+			_ = json.NewEncoder(w).Encode(rpcFailure(nil, RPCInvalidRequest, "invalid request"))
+			return
+		}
+
+		results := make([]*rpcResponse, 0, len(calls))
+		for _, call := range calls {
+			if resp := s.handle(ctx, call); resp != nil {
+				results = append(results, resp)
+			}
+		}
+
+		if len(results) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(results)
+		return
+	}
+
+	resp := s.handle(ctx, body)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// handle executes a single call. Notifications, calls without an id, return nil.
+func (s *RPCServer) handle(ctx context.Context, raw json.RawMessage) (resp *rpcResponse) {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" {
+		return rpcFailure(nil, RPCInvalidRequest, "invalid request")
+	}
+
+	notification := req.ID == nil
+
+	ctx, span := tracer.StartSpan(ctx, "RPC:"+req.Method)
+	defer span.End()
+	ctx = logger.ToContext(ctx, s.logFn().With("token", span.TraceId()))
+
+	defer func() {
+		if rec := recover(); rec != any(nil) {
+			logger.Error(ctx, fmt.Errorf("error: %v", rec), "critical error", "stacktrace", string(debug.Stack()))
+			resp = rpcFailure(req.ID, RPCInternalError, "internal error")
+		}
+
+		code := 0
+		if resp != nil && resp.Error != nil {
+			code = resp.Error.Code
+		}
+		requestCounter.WithLabelValues("RPC:"+req.Method, fmt.Sprintf("%d", code)).Inc()
+		span.Tag("rpc.code", code)
+		logger.Info(ctx, "rpc request", "method", req.Method, "params", string(req.Params), "code", code)
+
+		if notification {
+			resp = nil
+		}
+	}()
+
+	result, rpcErr := s.call(ctx, req.Method, req.Params)
+	if rpcErr != nil {
+		return &rpcResponse{JSONRPC: "2.0", Error: rpcErr, ID: req.ID}
+	}
+	return &rpcResponse{JSONRPC: "2.0", Result: result, ID: req.ID}
+}
+
+func (s *RPCServer) call(ctx context.Context, name string, params json.RawMessage) (json.RawMessage, *RPCError) {
+	s.mu.RLock()
+	m, ok := s.methods[name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, &RPCError{Code: RPCMethodNotFound, Message: "method not found"}
+	}
+
+	return m.call(ctx, params)
+}
+
+// Methods returns the registered methods sorted by name, without the reserved rpc.discover.
+func (s *RPCServer) Methods() []RPCMethodInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]RPCMethodInfo, 0, len(s.methods))
+	for name, m := range s.methods {
+		if !strings.HasPrefix(name, "rpc.") {
+			infos = append(infos, m.info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+func rpcFailure(id json.RawMessage, code int, msg string) *rpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &rpcResponse{JSONRPC: "2.0", Error: &RPCError{Code: code, Message: msg}, ID: id}
+}
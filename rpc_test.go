@@ -0,0 +1,128 @@
+package resty
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/porebric/logger"
+	"github.com/porebric/resty/authz"
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/requests"
+	"github.com/porebric/resty/responses"
+)
+
+// newTestRouter returns a router without the global metrics and debug routes of NewRouter.
+func newTestRouter() *router {
+	errors.Init(nil)
+	return &router{router: mux.NewRouter(), logFn: func() *logger.Logger { return logger.New(logger.InfoLevel) }}
+}
+
+type echoRequest struct {
+	Text string `json:"text"`
+}
+
+func (r *echoRequest) Validate() (bool, string, string) { return true, "", "" }
+func (r *echoRequest) Methods() []string                { return []string{http.MethodPost} }
+func (r *echoRequest) Path() (string, bool)             { return "/echo", false }
+func (r *echoRequest) String() string                   { return r.Text }
+
+func echo(_ context.Context, req *echoRequest) (responses.Response, int) {
+	return &responses.JSON[string]{Data: req.Text}, http.StatusOK
+}
+
+// resolveHeader makes requests with an X-User header authenticated.
+var resolveHeader = authz.Resolve(func(ctx context.Context) (authz.Principal, bool) {
+	if user := requests.HTTPRequest(ctx).Header.Get("X-User"); user != "" {
+		return &authz.Claims{Subject: user}, true
+	}
+	return nil, false
+})
+
+func callRPC(t *testing.T, r *router, method, user string) rpcResponse {
+	t.Helper()
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":{"text":"hi"}}`
+	req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+
+	w := httptest.NewRecorder()
+	r.router.ServeHTTP(w, req)
+
+	var resp rpcResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return resp
+}
+
+func TestRPCDenyByDefault(t *testing.T) {
+	r := newTestRouter()
+	r.DenyByDefault()
+
+	s := NewRPC(r, "/rpc")
+	RPCMethod(s, "closed", echo)
+	RPCMethod(s, "open", echo, authz.Require(authz.Public()))
+
+	if resp := callRPC(t, r, "closed", "alice"); resp.Error == nil || resp.Error.Code != RPCServerError-errors.ErrorInvalidAccess {
+		t.Fatalf("closed: got %+v, want access denied", resp)
+	}
+	if resp := callRPC(t, r, "open", ""); resp.Error != nil || string(resp.Result) != `"hi"` {
+		t.Fatalf("open: got %+v, want the result", resp)
+	}
+}
+
+func TestRPCGroupPolicy(t *testing.T) {
+	r := newTestRouter()
+	r.Authorize(RPCGroup, authz.Authenticated())
+
+	s := NewRPC(r, "/rpc")
+	RPCMethod(s, "echo", echo, resolveHeader)
+
+	if resp := callRPC(t, r, "echo", ""); resp.Error == nil || resp.Error.Code != RPCServerError-errors.ErrorUserUnauthorized {
+		t.Fatalf("anonymous: got %+v, want unauthorized", resp)
+	}
+	if resp := callRPC(t, r, "echo", "alice"); resp.Error != nil || string(resp.Result) != `"hi"` {
+		t.Fatalf("authenticated: got %+v, want the result", resp)
+	}
+}
+
+func TestRPCHeadStatus(t *testing.T) {
+	r := newTestRouter()
+
+	s := NewRPC(r, "/rpc")
+	RPCMethod(s, "find", func(context.Context, *echoRequest) (responses.Response, int) {
+		resp := &responses.JSON[string]{Data: "no such item"}
+		resp.Status = http.StatusNotFound
+		return resp, http.StatusOK
+	})
+
+	if resp := callRPC(t, r, "find", ""); resp.Error == nil || resp.Result != nil {
+		t.Fatalf("got %+v, want an error for the 404 of the head", resp)
+	}
+}
+
+func TestRPCDiscoverDenyByDefault(t *testing.T) {
+	r := newTestRouter()
+	r.DenyByDefault()
+
+	s := NewRPC(r, "/rpc")
+	RPCMethod(s, "echo", echo, authz.Require(authz.Public()))
+
+	if resp := callRPC(t, r, "rpc.discover", ""); resp.Error == nil || resp.Error.Code != RPCServerError-errors.ErrorInvalidAccess {
+		t.Fatalf("closed: got %+v, want access denied", resp)
+	}
+
+	s.Discover(authz.Require(authz.Public()))
+	resp := callRPC(t, r, "rpc.discover", "")
+	var methods []RPCMethodInfo
+	if resp.Error != nil || json.Unmarshal(resp.Result, &methods) != nil || len(methods) != 1 || methods[0].Name != "echo" {
+		t.Fatalf("open: got %+v, want the echo method", resp)
+	}
+}
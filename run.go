@@ -11,8 +11,30 @@ import (
 	"github.com/rs/cors"
 )
 
-func RunServer(ctx context.Context, router Router, closerFns ...func(ctx context.Context) error) {
+// RunServer serves router until ctx is done and then runs the closers. Invalid routes are returned
+// without serving, the closers still run so resources opened before are released.
+func RunServer(ctx context.Context, router Router, closerFns ...func(ctx context.Context) error) error {
+	rr := asRouter(router)
+
 	c := &closer.Closer{}
+	if rr != nil {
+		for _, fn := range rr.shutdown {
+			c.Add(fn)
+		}
+	}
+	for _, closerFn := range closerFns {
+		c.Add(closerFn)
+	}
+
+	opt := newOptions(ctx)
+
+	if rr != nil {
+		if err := rr.validate(); err != nil {
+			logger.Error(ctx, err, "invalid routes")
+			shutdown(ctx, c, opt)
+			return err
+		}
+	}
 
 	if router.GetWsHub() != nil {
 		go router.GetWsHub().Run()
@@ -22,12 +44,6 @@ func RunServer(ctx context.Context, router Router, closerFns ...func(ctx context
 		})
 	}
 
-	for _, closerFn := range closerFns {
-		c.Add(closerFn)
-	}
-
-	opt := newOptions(ctx)
-
 	var routerHandler http.Handler
 
 	if len(router.CorsAllowedOrigins()) != 0 || len(router.CorsAllowedMethods()) != 0 || len(router.CorsAllowedHeaders()) != 0 {
@@ -49,11 +65,16 @@ func RunServer(ctx context.Context, router Router, closerFns ...func(ctx context
 	logger.Info(ctx, "start server", "port", opt.Port)
 	<-ctx.Done()
 
+	shutdown(ctx, c, opt)
+	logger.Info(ctx, "stop")
+	return nil
+}
+
+func shutdown(ctx context.Context, c *closer.Closer, opt *options) {
 	shutdownCtx, cancel := context.WithTimeout(logger.ToContext(context.Background(), logger.FromContext(ctx)), opt.Timeout)
 	defer cancel()
 
 	if err := c.Close(shutdownCtx); err != nil {
 		logger.Error(ctx, err, "shutdown")
 	}
-	logger.Info(ctx, "stop")
 }
@@ -0,0 +1,143 @@
+package secure
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/porebric/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var violations = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "csp_violations_total",
+		Help: "The number of reported Content-Security-Policy violations, tracked by directive.",
+	},
+	[]string{"directive"},
+)
+
+// Report is a CSP violation, sent either as application/csp-report or application/reports+json.
+type Report struct {
+	DocumentURI        string `json:"document-uri"`
+	Referrer           string `json:"referrer"`
+	BlockedURI         string `json:"blocked-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	OriginalPolicy     string `json:"original-policy"`
+	Disposition        string `json:"disposition"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+	ColumnNumber       int    `json:"column-number"`
+	StatusCode         int    `json:"status-code"`
+}
+
+// reportingBody is the body of a csp-violation in the Reporting API, which uses camelCase.
+type reportingBody struct {
+	DocumentURL        string `json:"documentURL"`
+	Referrer           string `json:"referrer"`
+	BlockedURL         string `json:"blockedURL"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	OriginalPolicy     string `json:"originalPolicy"`
+	Disposition        string `json:"disposition"`
+	SourceFile         string `json:"sourceFile"`
+	LineNumber         int    `json:"lineNumber"`
+	ColumnNumber       int    `json:"columnNumber"`
+	StatusCode         int    `json:"statusCode"`
+}
+
+const maxReportSize = 64 << 10
+
+// ReportHandler collects CSP violation reports and answers 204. Reports are counted and passed to fn,
+// or logged if fn is nil. The endpoint must be exempt from CSRF protection, browsers send no token.
+func ReportHandler(fn func(ctx context.Context, report Report)) http.Handler {
+	if fn == nil {
+		fn = func(ctx context.Context, report Report) {
+			logger.Warn(ctx, "csp violation", "directive", report.EffectiveDirective,
+				"blocked", report.BlockedURI, "document", report.DocumentURI, "disposition", report.Disposition)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxReportSize))
+		if err != nil {
+			http.Error(w, "report too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		reports, err := parseReports(r.Header.Get("Content-Type"), body)
+		if err != nil {
+			http.Error(w, "invalid report", http.StatusBadRequest)
+			return
+		}
+
+		for _, report := range reports {
+			if report.EffectiveDirective == "" {
+				report.EffectiveDirective = report.ViolatedDirective
+			}
+			violations.WithLabelValues(directiveLabel(report.EffectiveDirective)).Inc()
+			fn(r.Context(), report)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// directiveLabel keeps the metric bounded, reports are sent by anyone who can reach the endpoint.
+func directiveLabel(directive string) string {
+	name, _, _ := strings.Cut(directive, " ")
+	switch name {
+	case "default-src", "script-src", "script-src-elem", "script-src-attr", "style-src", "style-src-elem",
+		"style-src-attr", "img-src", "font-src", "connect-src", "media-src", "object-src", "frame-src",
+		"child-src", "worker-src", "manifest-src", "form-action", "frame-ancestors", "base-uri":
+		return name
+	default:
+		return "other"
+	}
+}
+
+func parseReports(contentType string, body []byte) ([]Report, error) {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if mt == "application/reports+json" {
+		var items []struct {
+			Type string        `json:"type"`
+			Body reportingBody `json:"body"`
+		}
+		if err := json.Unmarshal(body, &items); err != nil {
+			return nil, err
+		}
+
+		reports := make([]Report, 0, len(items))
+		for _, item := range items {
+			if item.Type != "csp-violation" {
+				continue
+			}
+			b := item.Body
+			reports = append(reports, Report{
+				DocumentURI:        b.DocumentURL,
+				Referrer:           b.Referrer,
+				BlockedURI:         b.BlockedURL,
+				EffectiveDirective: b.EffectiveDirective,
+				OriginalPolicy:     b.OriginalPolicy,
+				Disposition:        b.Disposition,
+				SourceFile:         b.SourceFile,
+				LineNumber:         b.LineNumber,
+				ColumnNumber:       b.ColumnNumber,
+				StatusCode:         b.StatusCode,
+			})
+		}
+		return reports, nil
+	}
+
+	var legacy struct {
+		Report Report `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &legacy); err != nil {
+		return nil, err
+	}
+	return []Report{legacy.Report}, nil
+}
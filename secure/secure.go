@@ -0,0 +1,164 @@
+// Package secure sets the security headers browsers rely on: HSTS, X-Content-Type-Options, Referrer-Policy,
+// Permissions-Policy, X-Frame-Options and Content-Security-Policy.
+//
+//	headers := secure.New(secure.Options{
+//		Policy: secure.Policy{
+//			CSP:       "default-src 'self'; script-src 'self' 'nonce-{nonce}'",
+//			ReportURI: "/csp-report",
+//		},
+//		Routes: map[string]secure.Policy{"docs": {FrameOptions: "SAMEORIGIN"}},
+//	})
+//	router.MuxRouter().Use(headers.Handler)
+//	router.MuxRouter().Handle("/csp-report", secure.ReportHandler(nil)).Methods(http.MethodPost)
+//	router.ServeStatic("/", dist, resty.StaticOptions{SPA: true, Middlewares: []mux.MiddlewareFunc{headers.Handler}})
+//
+// Router middlewares do not run for requests matching no route, so files served by ServeStatic get the headers
+// only through StaticOptions.Middlewares. A handler used outside the router is wrapped directly, headers.Handler(h).
+//
+// {nonce} in the CSP is replaced by a random value per request, templates read it with Nonce(ctx). Static files
+// cannot carry the nonce, their scripts are allowed by 'self' or hashes.
+//
+// Browsers post CSP reports without a CSRF token, so with the csrf package the report endpoint is listed in
+// csrf.Options.ExemptPaths or ExemptRoutes.
+package secure
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Omit drops a header that is set by default.
+const Omit = "-"
+
+// Policy holds the header values. Empty fields keep the default, or the base policy for route overrides.
+type Policy struct {
+	// HSTS is "max-age=31536000; includeSubDomains" by default.
+	HSTS string
+	// ContentTypeOptions is "nosniff" by default.
+	ContentTypeOptions string
+	// ReferrerPolicy is "strict-origin-when-cross-origin" by default.
+	ReferrerPolicy string
+	// PermissionsPolicy is "camera=(), microphone=(), geolocation=()" by default.
+	PermissionsPolicy string
+	// FrameOptions is "DENY" by default.
+	FrameOptions string
+	// CSP is "default-src 'self'; base-uri 'self'; frame-ancestors 'none'; object-src 'none'" by default.
+	CSP string
+	// ReportOnly sends the CSP as Content-Security-Policy-Report-Only, violations are reported but not blocked.
+	ReportOnly *bool
+	// ReportURI is appended to the CSP as report-uri.
+	ReportURI string
+}
+
+var defaults = Policy{
+	HSTS:               "max-age=31536000; includeSubDomains",
+	ContentTypeOptions: "nosniff",
+	ReferrerPolicy:     "strict-origin-when-cross-origin",
+	PermissionsPolicy:  "camera=(), microphone=(), geolocation=()",
+	FrameOptions:       "DENY",
+	CSP:                "default-src 'self'; base-uri 'self'; frame-ancestors 'none'; object-src 'none'",
+}
+
+type Options struct {
+	Policy Policy
+	// Routes override the policy for routes by their RouteSpec.Name.
+	Routes map[string]Policy
+}
+
+type Headers struct {
+	policy Policy
+	routes map[string]Policy
+}
+
+func New(opts Options) *Headers {
+	h := &Headers{policy: merge(defaults, opts.Policy), routes: make(map[string]Policy, len(opts.Routes))}
+	for name, p := range opts.Routes {
+		h.routes[name] = merge(h.policy, p)
+	}
+	return h
+}
+
+type ctxKey struct{}
+
+// Nonce returns the CSP nonce of the request, empty if the CSP has no {nonce}.
+func Nonce(ctx context.Context) string {
+	n, _ := ctx.Value(ctxKey{}).(string)
+	return n
+}
+
+// Handler sets the headers before the route runs. It is a mux.MiddlewareFunc.
+func (h *Headers) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := h.policy
+		if route := mux.CurrentRoute(r); route != nil {
+			if rp, ok := h.routes[route.GetName()]; ok {
+				p = rp
+			}
+		}
+
+		header := w.Header()
+		set(header, "Strict-Transport-Security", p.HSTS)
+		set(header, "X-Content-Type-Options", p.ContentTypeOptions)
+		set(header, "Referrer-Policy", p.ReferrerPolicy)
+		set(header, "Permissions-Policy", p.PermissionsPolicy)
+		set(header, "X-Frame-Options", p.FrameOptions)
+
+		if p.CSP != Omit {
+			csp := p.CSP
+			if strings.Contains(csp, "{nonce}") {
+				nonce := newNonce()
+				csp = strings.ReplaceAll(csp, "{nonce}", nonce)
+				r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, nonce))
+			}
+			if p.ReportURI != "" && p.ReportURI != Omit {
+				csp += "; report-uri " + p.ReportURI
+			}
+
+			name := "Content-Security-Policy"
+			if p.ReportOnly != nil && *p.ReportOnly {
+				name = "Content-Security-Policy-Report-Only"
+			}
+			header.Set(name, csp)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func set(header http.Header, name, value string) {
+	if value != Omit {
+		header.Set(name, value)
+	}
+}
+
+func merge(base, p Policy) Policy {
+	pick := func(v, fallback string) string {
+		if v == "" {
+			return fallback
+		}
+		return v
+	}
+
+	base.HSTS = pick(p.HSTS, base.HSTS)
+	base.ContentTypeOptions = pick(p.ContentTypeOptions, base.ContentTypeOptions)
+	base.ReferrerPolicy = pick(p.ReferrerPolicy, base.ReferrerPolicy)
+	base.PermissionsPolicy = pick(p.PermissionsPolicy, base.PermissionsPolicy)
+	base.FrameOptions = pick(p.FrameOptions, base.FrameOptions)
+	base.CSP = pick(p.CSP, base.CSP)
+	base.ReportURI = pick(p.ReportURI, base.ReportURI)
+	if p.ReportOnly != nil {
+		base.ReportOnly = p.ReportOnly
+	}
+	return base
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,190 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/porebric/logger"
+)
+
+type Options struct {
+	Store Store
+	// Name of the cookie, "session" by default.
+	Name   string
+	Path   string
+	Domain string
+	// Insecure allows the cookie over plain HTTP, for local development.
+	Insecure bool
+	// SameSite is http.SameSiteLaxMode by default.
+	SameSite http.SameSite
+	// IdleTimeout ends sessions not used for this long, 30 minutes by default.
+	IdleTimeout time.Duration
+	// AbsoluteTimeout ends sessions this long after they were created or regenerated, 24 hours by default.
+	AbsoluteTimeout time.Duration
+}
+
+type Manager struct {
+	opts Options
+}
+
+func New(opts Options) *Manager {
+	if opts.Name == "" {
+		opts.Name = "session"
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = 30 * time.Minute
+	}
+	if opts.AbsoluteTimeout <= 0 {
+		opts.AbsoluteTimeout = 24 * time.Hour
+	}
+	return &Manager{opts: opts}
+}
+
+// Handler loads the session of the request into its context and saves it before the response is written.
+// It is a mux.MiddlewareFunc.
+func (m *Manager) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := m.load(r)
+
+		sw := &writer{ResponseWriter: w, save: func() { m.save(w, r, s) }}
+		next.ServeHTTP(sw, r.WithContext(toContext(r.Context(), s)))
+		sw.saveOnce()
+	})
+}
+
+func (m *Manager) load(r *http.Request) *Session {
+	c, err := r.Cookie(m.opts.Name)
+	if err != nil || c.Value == "" {
+		return newSession()
+	}
+
+	rec, err := m.opts.Store.Load(r.Context(), c.Value)
+	if err != nil {
+		logger.Warn(r.Context(), "load session", "error", err)
+	}
+	if rec == nil {
+		return newSession()
+	}
+
+	now := time.Now()
+	if now.Sub(rec.LastSeen) > m.opts.IdleTimeout || now.Sub(rec.CreatedAt) > m.opts.AbsoluteTimeout {
+		if err := m.opts.Store.Delete(r.Context(), rec.ID); err != nil {
+			logger.Warn(r.Context(), "delete session", "error", err)
+		}
+		return newSession()
+	}
+
+	if rec.Values == nil {
+		rec.Values = make(map[string]json.RawMessage)
+	}
+	return &Session{rec: *rec}
+}
+
+// touchInterval limits how often an unchanged session is saved to move its idle timeout.
+const touchInterval = time.Minute
+
+func (m *Manager) save(w http.ResponseWriter, r *http.Request, s *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx := r.Context()
+
+	if s.previous != "" {
+		if err := m.opts.Store.Delete(ctx, s.previous); err != nil {
+			logger.Warn(ctx, "delete session", "error", err)
+		}
+	}
+
+	if s.destroyed {
+		if err := m.opts.Store.Delete(ctx, s.rec.ID); err != nil {
+			logger.Warn(ctx, "delete session", "error", err)
+		}
+		if !s.fresh {
+			http.SetCookie(w, m.cookie("", -1))
+		}
+		return
+	}
+
+	now := time.Now()
+	if s.fresh && len(s.rec.Values) == 0 || !s.modified && now.Sub(s.rec.LastSeen) < touchInterval {
+		return
+	}
+
+	s.rec.LastSeen = now
+	ttl := min(m.opts.IdleTimeout, m.opts.AbsoluteTimeout-now.Sub(s.rec.CreatedAt))
+
+	value, err := m.opts.Store.Save(ctx, &s.rec, ttl)
+	if err != nil {
+		logger.Error(ctx, err, "save session")
+		return
+	}
+	http.SetCookie(w, m.cookie(value, int(ttl.Seconds())))
+}
+
+func (m *Manager) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.opts.Name,
+		Value:    value,
+		Path:     m.opts.Path,
+		Domain:   m.opts.Domain,
+		MaxAge:   maxAge,
+		Secure:   !m.opts.Insecure,
+		HttpOnly: true,
+		SameSite: m.opts.SameSite,
+	}
+}
+
+// writer saves the session right before the status line, the last moment cookies can be set.
+type writer struct {
+	http.ResponseWriter
+	save  func()
+	saved bool
+}
+
+func (w *writer) saveOnce() {
+	if !w.saved {
+		w.saved = true
+		w.save()
+	}
+}
+
+func (w *writer) WriteHeader(code int) {
+	w.saveOnce()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	w.saveOnce()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *writer) Flush() {
+	w.saveOnce()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack saves the session and hands the connection over, for websockets. The session cookie is not sent.
+func (w *writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("session: response writer does not support hijacking")
+	}
+	w.saveOnce()
+	return h.Hijack()
+}
+
+func (w *writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
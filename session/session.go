@@ -0,0 +1,149 @@
+// Package session keeps browser sessions in a cookie or a server-side store.
+//
+// The handler of a Manager loads the session before the router and saves it before the response is written:
+//
+//	store, err := session.NewCookieStore(session.Key{Hash: hashKey, Block: blockKey})
+//	if err != nil {
+//		return err
+//	}
+//	sessions := session.New(session.Options{Store: store})
+//	router.MuxRouter().Use(sessions.Handler)
+//
+// Actions read and change it through the context:
+//
+//	s := session.FromContext(ctx)
+//	s.Regenerate()
+//	_ = s.Set("user_id", user.ID)
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Record is the persisted state of a session.
+type Record struct {
+	ID        string                     `json:"id"`
+	Values    map[string]json.RawMessage `json:"values,omitempty"`
+	CreatedAt time.Time                  `json:"created_at"`
+	LastSeen  time.Time                  `json:"last_seen"`
+}
+
+// clone copies rec with its own Values, so the copy can be changed by another request.
+func (rec *Record) clone() *Record {
+	cp := *rec
+	cp.Values = make(map[string]json.RawMessage, len(rec.Values))
+	for k, v := range rec.Values {
+		cp.Values[k] = v
+	}
+	return &cp
+}
+
+type Session struct {
+	mu  sync.Mutex
+	rec Record
+
+	// previous is the id replaced by Regenerate, deleted from the store on save.
+	previous  string
+	fresh     bool
+	modified  bool
+	destroyed bool
+}
+
+func newSession() *Session {
+	now := time.Now()
+	return &Session{rec: Record{ID: newID(), Values: make(map[string]json.RawMessage), CreatedAt: now, LastSeen: now}, fresh: true}
+}
+
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rec.ID
+}
+
+// IsNew reports whether the session was created by this request.
+func (s *Session) IsNew() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.fresh
+}
+
+func (s *Session) CreatedAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rec.CreatedAt
+}
+
+// Get decodes the value of key into dst and reports whether it exists.
+func (s *Session) Get(key string, dst any) bool {
+	s.mu.Lock()
+	raw, ok := s.rec.Values[key]
+	s.mu.Unlock()
+
+	return ok && json.Unmarshal(raw, dst) == nil
+}
+
+// Set stores value, encoded as JSON, under key.
+func (s *Session) Set(key string, value any) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rec.Values[key] = raw
+	s.modified = true
+	return nil
+}
+
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rec.Values[key]; ok {
+		delete(s.rec.Values, key)
+		s.modified = true
+	}
+}
+
+// Regenerate gives the session a new id and keeps its values. Call it on login and privilege changes,
+// so an id planted before cannot be used afterwards.
+func (s *Session) Regenerate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.previous == "" && !s.fresh {
+		s.previous = s.rec.ID
+	}
+	s.rec.ID = newID()
+	s.rec.CreatedAt = time.Now()
+	s.modified = true
+}
+
+// Destroy drops the values and expires the cookie, like on logout.
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rec.Values = make(map[string]json.RawMessage)
+	s.destroyed = true
+}
+
+type ctxKey struct{}
+
+func toContext(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, ctxKey{}, s)
+}
+
+// FromContext returns the session of the request, nil if no Manager handled it.
+func FromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(ctxKey{}).(*Session)
+	return s
+}
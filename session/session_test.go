@@ -0,0 +1,138 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentSessions(t *testing.T) {
+	m := New(Options{Store: NewServerStore(NewMemoryBackend())})
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := FromContext(r.Context())
+		if key := r.URL.Query().Get("set"); key != "" {
+			if err := s.Set(key, key); err != nil {
+				t.Error(err)
+			}
+		}
+		var seen string
+		s.Get("login", &seen)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?set=login", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("cookies = %v", cookies)
+	}
+
+	// requests of the same session change copies of its record
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+
+			for j := 0; j < 10; j++ {
+				r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/?set=k%d", i), nil)
+				r.AddCookie(cookies[0])
+				h.ServeHTTP(httptest.NewRecorder(), r)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookies[0])
+	rec, err := m.opts.Store.Load(r.Context(), cookies[0].Value)
+	if err != nil || rec == nil {
+		t.Fatalf("load: %v, %v", rec, err)
+	}
+	if _, ok := rec.Values["login"]; !ok {
+		t.Fatalf("values = %v", rec.Values)
+	}
+}
+
+func TestMemoryBackendCopiesValues(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemoryBackend()
+
+	rec := &Record{ID: "s1", Values: map[string]json.RawMessage{"user_id": json.RawMessage(`1`)}}
+	if err := b.Set(ctx, rec, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	rec.Values["saved"] = json.RawMessage(`true`)
+
+	loaded, err := b.Get(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.Values["loaded"] = json.RawMessage(`true`)
+
+	stored, _ := b.Get(ctx, "s1")
+	if len(stored.Values) != 1 {
+		t.Fatalf("stored values = %v, want only user_id", stored.Values)
+	}
+}
+
+func TestNewCookieStoreRejectsWeakKeys(t *testing.T) {
+	hash := bytes.Repeat([]byte("h"), 32)
+	for name, tc := range map[string]struct {
+		keys []Key
+		err  error
+	}{
+		"no keys":     {nil, ErrNoKeys},
+		"short hash":  {[]Key{{Hash: []byte("short")}}, ErrShortHashKey},
+		"short block": {[]Key{{Hash: hash, Block: []byte("block")}}, ErrInvalidBlockKey},
+		"rotated":     {[]Key{{Hash: hash}, {Hash: []byte("old")}}, ErrShortHashKey},
+		"valid":       {[]Key{{Hash: hash, Block: bytes.Repeat([]byte("b"), 32)}}, nil},
+	} {
+		if _, err := NewCookieStore(tc.keys...); err != tc.err {
+			t.Errorf("%s: err = %v, want %v", name, err, tc.err)
+		}
+	}
+}
+
+func TestHijackThroughHandler(t *testing.T) {
+	m := New(Options{Store: NewServerStore(NewMemoryBackend())})
+	srv := httptest.NewServer(m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := FromContext(r.Context()).Set("login", "ok"); err != nil {
+			t.Error(err)
+		}
+		h, ok := w.(http.Hijacker)
+		if !ok {
+			t.Error("response writer is not a http.Hijacker")
+			return
+		}
+		conn, buf, err := h.Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		_, _ = buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\n")
+		_ = buf.Flush()
+	})))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+}
@@ -0,0 +1,256 @@
+package session
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	stderrors "errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCookieTooLarge  = stderrors.New("session: cookie value exceeds 4096 bytes")
+	ErrNoKeys          = stderrors.New("session: cookie store needs a key")
+	ErrShortHashKey    = stderrors.New("session: hash key must be at least 32 bytes")
+	ErrInvalidBlockKey = stderrors.New("session: block key must be 16, 24 or 32 bytes")
+)
+
+// Store persists sessions. The cookie carries the value returned by Save, Load returns nil for unknown,
+// tampered or expired values.
+type Store interface {
+	Load(ctx context.Context, value string) (*Record, error)
+	Save(ctx context.Context, rec *Record, ttl time.Duration) (string, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// Key signs and, if Block is set, encrypts cookies. Hash must be at least 32 bytes, Block 16, 24 or 32 bytes
+// for AES-GCM.
+type Key struct {
+	Hash  []byte
+	Block []byte
+}
+
+// CookieStore keeps the whole session in the cookie. The first key is used for new cookies, all keys are
+// accepted, so keys are rotated by prepending a new one and dropping the old one after the idle timeout.
+type CookieStore struct {
+	keys []Key
+}
+
+// NewCookieStore returns ErrNoKeys, ErrShortHashKey or ErrInvalidBlockKey for keys that would make
+// cookies forgeable or could not encrypt them.
+func NewCookieStore(keys ...Key) (*CookieStore, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	for _, key := range keys {
+		if len(key.Hash) < 32 {
+			return nil, ErrShortHashKey
+		}
+		if n := len(key.Block); key.Block != nil && n != 16 && n != 24 && n != 32 {
+			return nil, ErrInvalidBlockKey
+		}
+	}
+	return &CookieStore{keys: keys}, nil
+}
+
+func (s *CookieStore) Load(_ context.Context, value string) (*Record, error) {
+	payload, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, nil
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, nil
+	}
+
+	for _, key := range s.keys {
+		if !hmac.Equal(mac, sign(key.Hash, payload)) {
+			continue
+		}
+
+		if key.Block != nil {
+			if data, err = decrypt(key.Block, data); err != nil {
+				return nil, nil
+			}
+		}
+
+		var rec Record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, nil
+		}
+		return &rec, nil
+	}
+
+	return nil, nil
+}
+
+func (s *CookieStore) Save(_ context.Context, rec *Record, _ time.Duration) (string, error) {
+	key := s.keys[0]
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+
+	if key.Block != nil {
+		if data, err = encrypt(key.Block, data); err != nil {
+			return "", err
+		}
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	value := payload + "." + base64.RawURLEncoding.EncodeToString(sign(key.Hash, payload))
+	if len(value) > 4096 {
+		return "", ErrCookieTooLarge
+	}
+	return value, nil
+}
+
+// Delete does nothing, the expired cookie removes the session.
+func (s *CookieStore) Delete(context.Context, string) error {
+	return nil
+}
+
+func sign(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func encrypt(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+func decrypt(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, stderrors.New("session: short ciphertext")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Backend keeps records by id for a ServerStore, like Redis or a database table.
+// Get returns nil for unknown or expired ids.
+type Backend interface {
+	Get(ctx context.Context, id string) (*Record, error)
+	Set(ctx context.Context, rec *Record, ttl time.Duration) error
+	Delete(ctx context.Context, id string) error
+}
+
+// ServerStore keeps the session in a Backend, the cookie only carries its random id.
+type ServerStore struct {
+	backend Backend
+}
+
+func NewServerStore(backend Backend) *ServerStore {
+	return &ServerStore{backend: backend}
+}
+
+func (s *ServerStore) Load(ctx context.Context, value string) (*Record, error) {
+	return s.backend.Get(ctx, value)
+}
+
+func (s *ServerStore) Save(ctx context.Context, rec *Record, ttl time.Duration) (string, error) {
+	return rec.ID, s.backend.Set(ctx, rec, ttl)
+}
+
+func (s *ServerStore) Delete(ctx context.Context, id string) error {
+	return s.backend.Delete(ctx, id)
+}
+
+// MemoryBackend keeps copies of the records, expired ones are dropped at most once a minute.
+type MemoryBackend struct {
+	mu      sync.Mutex
+	records map[string]memoryRecord
+	swept   time.Time
+}
+
+type memoryRecord struct {
+	rec     Record
+	expires time.Time
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{records: make(map[string]memoryRecord)}
+}
+
+func (b *MemoryBackend) Get(_ context.Context, id string) (*Record, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, ok := b.records[id]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(r.expires) {
+		delete(b.records, id)
+		return nil, nil
+	}
+
+	return r.rec.clone(), nil
+}
+
+func (b *MemoryBackend) Set(_ context.Context, rec *Record, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(b.swept) > time.Minute {
+		for id, r := range b.records {
+			if now.After(r.expires) {
+				delete(b.records, id)
+			}
+		}
+		b.swept = now
+	}
+
+	b.records[rec.ID] = memoryRecord{rec: *rec.clone(), expires: now.Add(ttl)}
+	return nil
+}
+
+func (b *MemoryBackend) Delete(_ context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.records, id)
+	return nil
+}
+
+func newID() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
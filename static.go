@@ -0,0 +1,216 @@
+package resty
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+type StaticOptions struct {
+	// Index is served for directories and, in SPA mode, for unknown paths. Defaults to index.html.
+	Index string
+	// SPA serves Index for unknown paths without a file extension, so client side routes can be reloaded.
+	SPA bool
+	// CacheControl is matched in order against the file name relative to the root, the first match wins.
+	CacheControl []CacheRule
+	// ExcludePrefixes are never served from the file system and fall through to the NotFound handler,
+	// e.g. "/api/".
+	ExcludePrefixes []string
+	// Middlewares run around the served files, like secure.Headers.Handler. The router middlewares do not run
+	// for them, static files are served by the NotFound handler.
+	Middlewares []mux.MiddlewareFunc
+}
+
+// CacheRule sets the Cache-Control header for files matching Pattern, a path.Match pattern like "assets/*.js".
+// A pattern without a slash is matched against the base name.
+type CacheRule struct {
+	Pattern string
+	Value   string
+}
+
+// ServeStatic serves fsys under prefix for GET and HEAD requests that match no endpoint.
+// A precompressed name.gz variant is served to clients accepting gzip.
+func (r *router) ServeStatic(prefix string, fsys fs.FS, opts StaticOptions) {
+	if opts.Index == "" {
+		opts.Index = "index.html"
+	}
+
+	h := &staticHandler{
+		prefix: "/" + strings.Trim(prefix, "/"),
+		fsys:   fsys,
+		opts:   opts,
+		next:   r.router.NotFoundHandler,
+	}
+
+	h.files = http.HandlerFunc(h.serveResolved)
+	for i := len(opts.Middlewares) - 1; i >= 0; i-- {
+		h.files = opts.Middlewares[i](h.files)
+	}
+	r.router.NotFoundHandler = h
+}
+
+type staticHandler struct {
+	prefix string
+	fsys   fs.FS
+	opts   StaticOptions
+	next   http.Handler
+	etags  sync.Map
+
+	// files serves the file resolved for the request through the middlewares.
+	files http.Handler
+}
+
+type staticNameKey struct{}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	name, ok := h.resolve(r.URL.Path)
+	if !ok {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	h.files.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), staticNameKey{}, name)))
+}
+
+func (h *staticHandler) serveResolved(w http.ResponseWriter, r *http.Request) {
+	name, _ := r.Context().Value(staticNameKey{}).(string)
+	if err := h.serveFile(w, r, name); err != nil {
+		h.next.ServeHTTP(w, r)
+	}
+}
+
+// resolve maps a request path to a file name inside fsys.
+func (h *staticHandler) resolve(urlPath string) (string, bool) {
+	for _, excluded := range h.opts.ExcludePrefixes {
+		if strings.HasPrefix(urlPath, excluded) {
+			return "", false
+		}
+	}
+
+	if h.prefix != "/" {
+		if urlPath != h.prefix && !strings.HasPrefix(urlPath, h.prefix+"/") {
+			return "", false
+		}
+		urlPath = strings.TrimPrefix(urlPath, h.prefix)
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == "" {
+		return h.opts.Index, true
+	}
+
+	info, err := fs.Stat(h.fsys, name)
+	switch {
+	case err == nil && info.IsDir():
+		return path.Join(name, h.opts.Index), true
+	case err == nil:
+		return name, true
+	case h.opts.SPA && path.Ext(name) == "":
+		return h.opts.Index, true
+	default:
+		return "", false
+	}
+}
+
+// serveFile sets the headers only once the file is ready to be served, so an error leaves them untouched
+// for the not found response.
+func (h *staticHandler) serveFile(w http.ResponseWriter, r *http.Request, name string) error {
+	served := name
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		if _, err := fs.Stat(h.fsys, name+".gz"); err == nil {
+			served = name + ".gz"
+		}
+	}
+
+	f, err := h.fsys.Open(served)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return fmt.Errorf("%s is not a file", served)
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(data)
+	}
+
+	etag, err := h.etag(served, info, content)
+	if err != nil {
+		return err
+	}
+
+	if served != name {
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", h.cacheControl(name))
+
+	http.ServeContent(w, r, name, info.ModTime(), content)
+	return nil
+}
+
+// etag hashes the file content once per name, size and modification time.
+func (h *staticHandler) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	key := fmt.Sprintf("%s:%d:%d", name, info.Size(), info.ModTime().UnixNano())
+	if etag, ok := h.etags.Load(key); ok {
+		return etag.(string), nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	h.etags.Store(key, etag)
+	return etag, nil
+}
+
+func (h *staticHandler) cacheControl(name string) string {
+	for _, rule := range h.opts.CacheControl {
+		target := name
+		if !strings.Contains(rule.Pattern, "/") {
+			target = path.Base(name)
+		}
+
+		if ok, _ := path.Match(rule.Pattern, target); ok {
+			return rule.Value
+		}
+	}
+
+	if name == h.opts.Index || path.Base(name) == h.opts.Index {
+		return "no-cache"
+	}
+	return "public, max-age=3600"
+}
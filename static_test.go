@@ -0,0 +1,33 @@
+package resty
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gorilla/mux"
+	"github.com/porebric/resty/secure"
+)
+
+func TestServeStaticMiddlewares(t *testing.T) {
+	r := newTestRouter()
+	headers := secure.New(secure.Options{Policy: secure.Policy{CSP: "script-src 'nonce-{nonce}'"}})
+	dist := fstest.MapFS{"index.html": {Data: []byte("<html></html>")}}
+	r.ServeStatic("/", dist, StaticOptions{SPA: true, Middlewares: []mux.MiddlewareFunc{headers.Handler}})
+
+	for _, path := range []string{"/", "/index.html", "/settings"} {
+		w := httptest.NewRecorder()
+		r.MuxRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", path, w.Code)
+		}
+		if csp := w.Header().Get("Content-Security-Policy"); csp == "" || csp == "script-src 'nonce-{nonce}'" {
+			t.Fatalf("%s: csp = %q", path, csp)
+		}
+		if w.Header().Get("X-Frame-Options") != "DENY" {
+			t.Fatalf("%s: headers = %v", path, w.Header())
+		}
+	}
+}
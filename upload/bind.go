@@ -0,0 +1,395 @@
+// Package upload binds multipart/form-data requests into request structs.
+//
+// Value parts are bound to fields tagged `form:"name"`, file parts to *File or []*File fields tagged
+// `file:"name"`. The `accept` tag narrows the allowed content types of a single file field:
+//
+//	type AvatarRequest struct {
+//		UserId int          `form:"user_id"`
+//		Avatar *upload.File `file:"avatar" accept:"image/png,image/jpeg"`
+//	}
+package upload
+
+import (
+	"bufio"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/porebric/logger"
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/internal/cleanup"
+	"github.com/porebric/resty/requests"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const sniffLen = 512
+
+var (
+	uploadSize = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_upload_size_bytes",
+			Help:    "The size of uploaded files, tracked by form field.",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 10),
+		},
+		[]string{"field"},
+	)
+	uploadRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_upload_rejected_total",
+			Help: "The number of rejected uploads, tracked by form field and reason.",
+		},
+		[]string{"field", "reason"},
+	)
+)
+
+var errFileTooLarge = stderrors.New("file too large")
+
+type Options struct {
+	// MaxFileSize limits every single file, 10 MB by default.
+	MaxFileSize int64
+	// MaxTotalSize limits the whole request body, 32 MB by default.
+	MaxTotalSize int64
+	// MaxValueSize limits every value part, 1 MB by default.
+	MaxValueSize int64
+	// AllowedTypes lists sniffed content types accepted for files without an accept tag, like "image/*".
+	// Empty means any type.
+	AllowedTypes []string
+	// Storage keeps the files, TempStorage by default.
+	Storage Storage
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxFileSize == 0 {
+		o.MaxFileSize = 10 << 20
+	}
+	if o.MaxTotalSize == 0 {
+		o.MaxTotalSize = 32 << 20
+	}
+	if o.MaxValueSize == 0 {
+		o.MaxValueSize = 1 << 20
+	}
+	if o.Storage == nil {
+		o.Storage = TempStorage{}
+	}
+	return o
+}
+
+// Bind returns a request initializer for Endpoint that streams a multipart body into R.
+// Stored files are removed after the action. It panics if the fields of R cannot be bound.
+func Bind[R requests.Request](opts Options) func(ctx context.Context, r *http.Request) (context.Context, R, error) {
+	opts = opts.withDefaults()
+
+	_, target := newTarget[R]()
+	fields, err := formFields(target.Type())
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return func(ctx context.Context, r *http.Request) (context.Context, R, error) {
+		req, target := newTarget[R]()
+
+		err := opts.bind(ctx, r, target, fields)
+		return ctx, req(), err
+	}
+}
+
+// newTarget allocates R and returns it with the struct value to bind into.
+func newTarget[R requests.Request]() (func() R, reflect.Value) {
+	t := reflect.TypeFor[R]()
+	if t.Kind() == reflect.Pointer {
+		v := reflect.New(t.Elem())
+		return func() R { return v.Interface().(R) }, v.Elem()
+	}
+
+	v := reflect.New(t).Elem()
+	return func() R { return v.Interface().(R) }, v
+}
+
+func (o Options) bind(ctx context.Context, r *http.Request, target reflect.Value, fields map[string]formField) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return errors.New(errors.ErrorUnsupportedMediaType, "multipart/form-data expected")
+	}
+
+	r.Body = http.MaxBytesReader(nil, r.Body, o.MaxTotalSize)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return errors.New(errors.ErrorInvalidRequest, "invalid multipart body")
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return o.partError("", err)
+		}
+
+		f, ok := fields[part.FormName()]
+		if !ok {
+			_, _ = io.Copy(io.Discard, part)
+			continue
+		}
+
+		body := &clientReader{r: part}
+		if f.file {
+			err = o.bindFile(ctx, part.FormName(), part.FileName(), body, f, target.Field(f.index))
+		} else {
+			err = o.bindValue(part.FormName(), body, target.Field(f.index))
+		}
+		_ = part.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (o Options) bindValue(name string, part io.Reader, field reflect.Value) error {
+	value, err := io.ReadAll(&limitReader{r: part, max: o.MaxValueSize})
+	if err != nil {
+		return o.partError(name, err)
+	}
+
+	if err = setValue(field, string(value)); err != nil {
+		return errors.New(errors.ErrorInvalidRequest, fmt.Sprintf("%s: invalid value", name))
+	}
+	return nil
+}
+
+func (o Options) bindFile(ctx context.Context, name, filename string, part io.Reader, f formField, field reflect.Value) error {
+	br := bufio.NewReaderSize(part, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return o.partError(name, err)
+	}
+
+	contentType := http.DetectContentType(head)
+	allowed := o.AllowedTypes
+	if len(f.accept) != 0 {
+		allowed = f.accept
+	}
+	if !typeAllowed(contentType, allowed) {
+		uploadRejected.WithLabelValues(name, "type").Inc()
+		return errors.New(errors.ErrorUnsupportedMediaType, fmt.Sprintf("%s: %s is not allowed", name, contentType))
+	}
+
+	file := &File{
+		Field:       name,
+		Filename:    path.Base(filename),
+		ContentType: contentType,
+		storage:     o.Storage,
+	}
+
+	file.Size, err = o.Storage.Save(ctx, file, &limitReader{r: br, max: o.MaxFileSize})
+	if file.Path != "" {
+		cleanup.Add(ctx, func() error { return file.Remove(context.WithoutCancel(ctx)) })
+	}
+	if err != nil {
+		if !stderrors.As(err, new(*clientError)) && !stderrors.Is(err, errFileTooLarge) {
+			logger.Error(ctx, err, "save upload", "field", name)
+		}
+		return o.partError(name, err)
+	}
+
+	uploadSize.WithLabelValues(name).Observe(float64(file.Size))
+
+	if field.Kind() == reflect.Slice {
+		field.Set(reflect.Append(field, reflect.ValueOf(file)))
+	} else {
+		field.Set(reflect.ValueOf(file))
+	}
+	return nil
+}
+
+// partError maps errors reading the request to client errors and the others, like storage failures,
+// to errors.ErrorCritical.
+func (o Options) partError(name string, err error) error {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case stderrors.As(err, &maxBytesErr):
+		uploadRejected.WithLabelValues(name, "total_size").Inc()
+		return errors.New(errors.ErrorRequestTooLarge, fmt.Sprintf("request exceeds %d bytes", o.MaxTotalSize))
+	case stderrors.Is(err, errFileTooLarge):
+		uploadRejected.WithLabelValues(name, "size").Inc()
+		return errors.New(errors.ErrorRequestTooLarge, fmt.Sprintf("%s: exceeds the size limit", name))
+	case name == "":
+		return errors.New(errors.ErrorInvalidRequest, "invalid multipart body")
+	case stderrors.As(err, new(*clientError)):
+		return errors.New(errors.ErrorInvalidRequest, fmt.Sprintf("%s: invalid multipart body", name))
+	default:
+		return errors.New(errors.ErrorCritical, "")
+	}
+}
+
+type formField struct {
+	index  int
+	file   bool
+	accept []string
+}
+
+var fileType = reflect.TypeOf(&File{})
+
+func formFields(t reflect.Type) (map[string]formField, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("upload: %s is not a struct", t)
+	}
+
+	fields := make(map[string]formField)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name := sf.Tag.Get("file"); name != "" {
+			if sf.Type != fileType && sf.Type != reflect.SliceOf(fileType) {
+				return nil, fmt.Errorf("upload: field %s.%s must be *upload.File or []*upload.File", t, sf.Name)
+			}
+
+			f := formField{index: i, file: true}
+			if accept := sf.Tag.Get("accept"); accept != "" {
+				f.accept = strings.Split(accept, ",")
+			}
+			fields[name] = f
+			continue
+		}
+
+		if name := sf.Tag.Get("form"); name != "" && name != "-" {
+			if !settable(sf.Type) {
+				return nil, fmt.Errorf("upload: field %s.%s has unsupported type %s", t, sf.Name, sf.Type)
+			}
+			fields[name] = formField{index: i}
+		}
+	}
+	return fields, nil
+}
+
+// settable reports whether setValue supports t.
+func settable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8 || t.Elem().Kind() != reflect.Slice && settable(t.Elem())
+	case reflect.Pointer:
+		return settable(t.Elem())
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func typeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, a := range allowed {
+		a = strings.TrimSpace(a)
+		if a == mediaType || strings.HasSuffix(a, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func setValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		elem := reflect.New(field.Type().Elem()).Elem()
+		if err := setValue(elem, value); err != nil {
+			return err
+		}
+		field.Set(reflect.Append(field, elem))
+		return nil
+	}
+
+	if field.Kind() == reflect.Pointer {
+		elem := reflect.New(field.Type().Elem())
+		if err := setValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case reflect.Slice:
+		field.SetBytes([]byte(value))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// clientError is an error reading a part from the client, as opposed to one of the Storage.
+type clientError struct {
+	err error
+}
+
+func (e *clientError) Error() string { return e.err.Error() }
+func (e *clientError) Unwrap() error { return e.err }
+
+// clientReader marks the read errors of a part as clientError.
+type clientReader struct {
+	r io.Reader
+}
+
+func (c *clientReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = &clientError{err: err}
+	}
+	return n, err
+}
+
+type limitReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.max {
+		return n, errFileTooLarge
+	}
+	return n, err
+}
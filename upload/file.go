@@ -0,0 +1,64 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// File is an uploaded file. Its content lives in the Storage it was saved to until the request is finished.
+type File struct {
+	Field       string
+	Filename    string
+	ContentType string
+	Size        int64
+	Path        string
+
+	storage Storage
+}
+
+func (f *File) Open(ctx context.Context) (io.ReadCloser, error) {
+	return f.storage.Open(ctx, f.Path)
+}
+
+// Remove deletes the file from the storage. Files bound in an endpoint are removed automatically after the action.
+func (f *File) Remove(ctx context.Context) error {
+	if f.Path == "" {
+		return nil
+	}
+	return f.storage.Remove(ctx, f.Path)
+}
+
+// Storage keeps uploaded content. Save must set f.Path and return the number of bytes written.
+type Storage interface {
+	Save(ctx context.Context, f *File, r io.Reader) (int64, error)
+	Open(ctx context.Context, path string) (io.ReadCloser, error)
+	Remove(ctx context.Context, path string) error
+}
+
+// TempStorage writes uploads to Dir, the system temp dir if empty.
+type TempStorage struct {
+	Dir string
+}
+
+func (s TempStorage) Save(_ context.Context, f *File, r io.Reader) (int64, error) {
+	tmp, err := os.CreateTemp(s.Dir, "upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tmp.Close() }()
+
+	f.Path = tmp.Name()
+	return io.Copy(tmp, r)
+}
+
+func (s TempStorage) Open(_ context.Context, path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+func (s TempStorage) Remove(_ context.Context, path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/porebric/resty/responses"
 	"net/http"
 	"runtime/debug"
 
@@ -12,28 +11,75 @@ import (
 	"github.com/porebric/resty/errors"
 	"github.com/porebric/resty/middleware"
 	"github.com/porebric/resty/requests"
+	"github.com/porebric/resty/responses"
+	"github.com/porebric/resty/validation"
 )
 
-func getDeferCatchPanic(log *logger.Logger, w http.ResponseWriter) {
+func getDeferCatchPanic(ctx context.Context, rw *responseWriter) {
 	if rec := recover(); rec != any(nil) {
-		logger.Error(
-			logger.ToContext(context.Background(), log),
-			fmt.Errorf("error: %v", rec), "critical error", "stacktrace", string(debug.Stack()),
-		)
-		resp, httpCode := errors.GetCustomError("", errors.ErrorCritical)
-		w.WriteHeader(httpCode)
-		_ = json.NewEncoder(w).Encode(resp)
+		logger.Error(ctx, fmt.Errorf("error: %v", rec), "critical error", "stacktrace", string(debug.Stack()))
+
+		writeCriticalError(rw)
+		return
+	}
+}
+
+// writeResponse renders resp with the given status. Responses carrying a Head override the status, and
+// responses depending on the request choose it themselves. If rendering fails before the response is
+// committed the partial output is dropped and replaced by a critical error.
+func writeResponse(ctx context.Context, rw *responseWriter, r *http.Request, httpCode int, resp responses.Response) {
+	if headed, ok := resp.(responses.Headed); ok {
+		headed.ResponseHead().Apply(rw)
+	}
+	httpCode = responseStatus(resp, httpCode)
+
+	var err error
+	if rr, ok := resp.(responses.RequestResponse); ok {
+		err = rr.ServeResponse(rw, r)
+	} else {
+		rw.WriteHeader(httpCode)
+		err = resp.PrepareResponse(rw)
+	}
+
+	if err == nil {
 		return
 	}
+
+	logger.Error(ctx, err, "prepare response")
+	writeCriticalError(rw)
 }
 
-func checkAction[R requests.Request](ctx context.Context, req R, w http.ResponseWriter, mm ...func() middleware.Middleware) (context.Context, *responses.ErrorResponse, int) {
+// responseStatus returns the status resp is answered with: the Status of its Head, else httpCode, else 200.
+func responseStatus(resp responses.Response, httpCode int) int {
+	if headed, ok := resp.(responses.Headed); ok {
+		if status := headed.ResponseHead().Status; status != 0 {
+			return status
+		}
+	}
+	if httpCode == 0 {
+		return http.StatusOK
+	}
+	return httpCode
+}
 
+// writeCriticalError replaces a not yet committed response with a critical error.
+func writeCriticalError(rw *responseWriter) {
+	if !rw.reset() {
+		return
+	}
+
+	resp, httpCode := errors.GetCustomError("", errors.ErrorCritical)
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(httpCode)
+	_ = json.NewEncoder(rw).Encode(resp)
+}
+
+func checkAction[R requests.Request](ctx context.Context, req R, mm ...func() middleware.Middleware) (context.Context, *responses.ErrorResponse, int) {
 	middlewares := make([]middleware.Middleware, 1, len(mm)+1)
 	middlewares[0] = new(middleware.RequestValidate)
 
 	if len(mm) == 0 {
-		return execute(ctx, middlewares, req, w)
+		return execute(ctx, middlewares, req)
 	}
 
 	for _, m := range mm {
@@ -43,16 +89,19 @@ func checkAction[R requests.Request](ctx context.Context, req R, w http.Response
 		middlewares = append(middlewares, newMiddleware)
 	}
 
-	return execute(ctx, middlewares, req, w)
+	return execute(ctx, middlewares, req)
 }
 
-func execute(ctx context.Context, mm []middleware.Middleware, req requests.Request, w http.ResponseWriter) (context.Context, *responses.ErrorResponse, int) {
+func execute(ctx context.Context, mm []middleware.Middleware, req requests.Request) (context.Context, *responses.ErrorResponse, int) {
 	checkRequest := &middleware.RequestCheck{}
 	mm[len(mm)-1].SetNext(checkRequest)
 	ctx, code, msg := mm[0].Execute(ctx, req)
 
 	if code != errors.ErrorNoError {
 		resp, httpCode := errors.GetCustomError(msg, code)
+		if result := validation.FromContext(ctx); !result.Valid() {
+			resp.Fields = result.Errors
+		}
 		if httpCode == 0 {
 			logger.Warn(ctx, "invalid middleware http code", "code", code)
 			httpCode = http.StatusBadRequest
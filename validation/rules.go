@@ -0,0 +1,255 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// RuleFunc reports whether value satisfies the rule. param is the text after the equal sign in the tag and
+// parent is the struct that holds the field, for rules comparing fields with each other.
+type RuleFunc func(value reflect.Value, param string, parent reflect.Value) bool
+
+type rule struct {
+	fn       RuleFunc
+	msg      string
+	presence bool
+	// checksEmpty rules also run for empty values that are not nil pointers.
+	checksEmpty bool
+}
+
+func (r rule) message(param string) string {
+	if strings.Contains(r.msg, "%s") {
+		return fmt.Sprintf(r.msg, param)
+	}
+	return r.msg
+}
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]rule{
+		"required":         {fn: required, msg: "is required", presence: true},
+		"required_with":    {fn: requiredWith, msg: "is required when %s is set", presence: true},
+		"required_without": {fn: requiredWithout, msg: "is required when %s is not set", presence: true},
+		"min":              {fn: minRule, msg: "must be at least %s", checksEmpty: true},
+		"max":              {fn: maxRule, msg: "must be at most %s", checksEmpty: true},
+		"len":              {fn: lenRule, msg: "must have length %s", checksEmpty: true},
+		"regex":            {fn: regexRule, msg: "must match %s"},
+		"oneof":            {fn: oneOf, msg: "must be one of %s"},
+		"email":            {fn: email, msg: "must be a valid email"},
+		"uuid":             {fn: uuidRule, msg: "must be a valid uuid"},
+		"eqfield":          {fn: compareField(func(c int) bool { return c == 0 }), msg: "must be equal to %s"},
+		"nefield":          {fn: compareField(func(c int) bool { return c != 0 }), msg: "must not be equal to %s"},
+		"gtfield":          {fn: compareField(func(c int) bool { return c > 0 }), msg: "must be greater than %s"},
+		"gtefield":         {fn: compareField(func(c int) bool { return c >= 0 }), msg: "must be greater than or equal to %s"},
+		"ltfield":          {fn: compareField(func(c int) bool { return c < 0 }), msg: "must be less than %s"},
+		"ltefield":         {fn: compareField(func(c int) bool { return c <= 0 }), msg: "must be less than or equal to %s"},
+	}
+
+	regexCache sync.Map
+
+	uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// Register adds a custom rule or replaces a built-in one. msg may contain %s for the tag parameter.
+// The rule is not called for empty values.
+func Register(name string, fn RuleFunc, msg string) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+
+	rules[name] = rule{fn: fn, msg: msg}
+}
+
+func getRule(name string) (rule, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	r, ok := rules[name]
+	return r, ok
+}
+
+func lookupRule(name string) rule {
+	r, _ := getRule(name)
+	return r
+}
+
+func required(v reflect.Value, _ string, _ reflect.Value) bool {
+	return !isEmpty(v)
+}
+
+func requiredWith(v reflect.Value, param string, parent reflect.Value) bool {
+	other := parent.FieldByName(param)
+	return !other.IsValid() || isEmpty(other) || !isEmpty(v)
+}
+
+func requiredWithout(v reflect.Value, param string, parent reflect.Value) bool {
+	other := parent.FieldByName(param)
+	return other.IsValid() && !isEmpty(other) || !isEmpty(v)
+}
+
+func minRule(v reflect.Value, param string, _ reflect.Value) bool {
+	n, ok := measure(v)
+	return ok && n >= parseFloat(param)
+}
+
+func maxRule(v reflect.Value, param string, _ reflect.Value) bool {
+	n, ok := measure(v)
+	return ok && n <= parseFloat(param)
+}
+
+func lenRule(v reflect.Value, param string, _ reflect.Value) bool {
+	n, ok := length(v)
+	return ok && float64(n) == parseFloat(param)
+}
+
+func regexRule(v reflect.Value, param string, _ reflect.Value) bool {
+	v = indirect(v)
+	return v.Kind() == reflect.String && compileRegex(param).MatchString(v.String())
+}
+
+func oneOf(v reflect.Value, param string, _ reflect.Value) bool {
+	s := fmt.Sprint(indirect(v).Interface())
+	for _, option := range strings.Fields(param) {
+		if s == option {
+			return true
+		}
+	}
+	return false
+}
+
+func email(v reflect.Value, _ string, _ reflect.Value) bool {
+	v = indirect(v)
+	if v.Kind() != reflect.String {
+		return false
+	}
+
+	addr, err := mail.ParseAddress(v.String())
+	return err == nil && addr.Address == v.String()
+}
+
+func uuidRule(v reflect.Value, _ string, _ reflect.Value) bool {
+	v = indirect(v)
+	return v.Kind() == reflect.String && uuidRegexp.MatchString(v.String())
+}
+
+func compareField(ok func(int) bool) RuleFunc {
+	return func(v reflect.Value, param string, parent reflect.Value) bool {
+		other := parent.FieldByName(param)
+		if !other.IsValid() {
+			return false
+		}
+
+		c, comparable := compare(indirect(v), indirect(other))
+		return comparable && ok(c)
+	}
+}
+
+func compare(a, b reflect.Value) (int, bool) {
+	if a.Type() == timeType && b.Type() == timeType {
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time)), true
+	}
+
+	if a.Kind() == reflect.String && b.Kind() == reflect.String {
+		return strings.Compare(a.String(), b.String()), true
+	}
+
+	x, okA := number(a)
+	y, okB := number(b)
+	if !okA || !okB {
+		return 0, false
+	}
+
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+// measure returns the number compared by min and max: the value of numbers and the length of everything else.
+func measure(v reflect.Value) (float64, bool) {
+	v = indirect(v)
+	if n, ok := number(v); ok {
+		return n, true
+	}
+
+	l, ok := length(v)
+	return float64(l), ok
+}
+
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+func length(v reflect.Value) (int, bool) {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), true
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return v.Len(), true
+	default:
+		return 0, false
+	}
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+func parseFloat(s string) float64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: invalid number %q", s))
+	}
+	return f
+}
+
+// checkParam validates the parameters of the built-in rules that parse them.
+func checkParam(name, param string) error {
+	switch name {
+	case "min", "max", "len":
+		if _, err := strconv.ParseFloat(param, 64); err != nil {
+			return fmt.Errorf("invalid number %q", param)
+		}
+	case "regex":
+		re, err := regexp.Compile(param)
+		if err != nil {
+			return err
+		}
+		regexCache.LoadOrStore(param, re)
+	default:
+	}
+	return nil
+}
+
+func compileRegex(expr string) *regexp.Regexp {
+	if cached, ok := regexCache.Load(expr); ok {
+		return cached.(*regexp.Regexp)
+	}
+
+	re := regexp.MustCompile(expr)
+	regexCache.Store(expr, re)
+	return re
+}
@@ -0,0 +1,305 @@
+// Package validation checks requests against `validate` struct tags and collects every violation.
+//
+// Rules are separated by commas, parameters follow an equal sign:
+//
+//	Name  string   `json:"name" validate:"required,min=3,max=64"`
+//	Kind  string   `json:"kind" validate:"oneof=user admin"`
+//	Email string   `json:"email" validate:"required,email"`
+//	Code  string   `json:"code" validate:"regex=^[A-Z]{2}-[0-9]+$"`
+//	Items []Item   `json:"items" validate:"min=1"`
+//
+// regex consumes the rest of the tag, so it must be the last rule. Empty values are only checked by the
+// presence rules (required, required_with, required_without) and by min, max and len, so a zero Age fails
+// min=18 and an empty Items fails min=1. Nil pointers are only checked by the presence rules, use them for
+// optional fields. Nested structs, pointers to structs and slices of structs are validated recursively,
+// zero structs included, and reported as "items[0].name". A field tagged
+// `validate:"inline"` is validated as if it was embedded, its fields are reported without a prefix.
+package validation
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type Result struct {
+	Errors []FieldError
+}
+
+func (r *Result) Valid() bool {
+	return r == nil || len(r.Errors) == 0
+}
+
+func (r *Result) Add(field, rule, msg string) {
+	r.Errors = append(r.Errors, FieldError{Field: field, Rule: rule, Message: msg})
+}
+
+// Error joins all violations into a single line.
+func (r *Result) Error() string {
+	msgs := make([]string, 0, len(r.Errors))
+	for _, e := range r.Errors {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", e.Field, e.Message))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type ctxKey struct{}
+
+func ToContext(ctx context.Context, r *Result) context.Context {
+	return context.WithValue(ctx, ctxKey{}, r)
+}
+
+func FromContext(ctx context.Context) *Result {
+	r, _ := ctx.Value(ctxKey{}).(*Result)
+	return r
+}
+
+// Struct validates v, a struct or a pointer to one. Other values are always valid.
+func Struct(v any) *Result {
+	res := new(Result)
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return res
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() == reflect.Struct {
+		validateStruct(rv, "", res)
+	}
+
+	return res
+}
+
+type tagRule struct {
+	name  string
+	param string
+}
+
+type field struct {
+	index    int
+	name     string
+	embedded bool
+	rules    []tagRule
+}
+
+var fieldsCache sync.Map
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func validateStruct(v reflect.Value, prefix string, res *Result) {
+	for _, f := range structFields(v.Type()) {
+		fv := v.Field(f.index)
+
+		if f.embedded {
+			validateEmbedded(fv, prefix, res)
+			continue
+		}
+
+		name := prefix + f.name
+		if !checkRules(fv, v, name, f.rules, res) {
+			continue
+		}
+
+		validateValue(fv, name, res)
+	}
+}
+
+// checkRules applies the tag rules of a field and reports whether nested values should be validated too.
+func checkRules(fv, parent reflect.Value, name string, rules []tagRule, res *Result) bool {
+	empty := isEmpty(fv)
+	isNil := (fv.Kind() == reflect.Pointer || fv.Kind() == reflect.Interface) && fv.IsNil()
+
+	for _, tr := range rules {
+		r := lookupRule(tr.name)
+		if empty && !r.presence && (isNil || !r.checksEmpty) {
+			continue
+		}
+
+		if !r.fn(fv, tr.param, parent) {
+			res.Add(name, tr.name, r.message(tr.param))
+			if tr.name == "required" {
+				return false
+			}
+		}
+	}
+
+	return !isNil
+}
+
+// validateEmbedded validates the fields of an embedded or inline struct with the prefix of its parent.
+func validateEmbedded(v reflect.Value, prefix string, res *Result) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Struct && v.Type() != timeType {
+		validateStruct(v, prefix, res)
+	}
+}
+
+func validateValue(v reflect.Value, name string, res *Result) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return
+		}
+		validateStruct(v, name+".", res)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", name, i), res)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			validateValue(iter.Value(), fmt.Sprintf("%s[%v]", name, iter.Key()), res)
+		}
+	default:
+	}
+}
+
+// Compile parses the tags of the struct type of v and of the structs it contains, so invalid tags are
+// reported when the endpoint is registered instead of on its first request.
+func Compile(v any) error {
+	return compileType(reflect.TypeOf(v), make(map[reflect.Type]bool))
+}
+
+func compileType(t reflect.Type, seen map[reflect.Type]bool) error {
+	for t != nil {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+			continue
+		default:
+		}
+		break
+	}
+
+	if t == nil || t.Kind() != reflect.Struct || t == timeType || seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	fields, err := loadFields(t)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		if err := compileType(t.Field(f.index).Type, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structFields panics on invalid tags, which Compile reports at registration.
+func structFields(t reflect.Type) []field {
+	fields, err := loadFields(t)
+	if err != nil {
+		panic(err.Error())
+	}
+	return fields
+}
+
+func loadFields(t reflect.Type) ([]field, error) {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.([]field), nil
+	}
+
+	fields := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		inline := sf.Tag.Get("validate") == "inline"
+		f := field{index: i, name: fieldName(sf), embedded: inline || sf.Anonymous && sf.Tag.Get("json") == ""}
+		if !inline {
+			rules, err := parseTag(t, sf)
+			if err != nil {
+				return nil, err
+			}
+			f.rules = rules
+		}
+
+		fields = append(fields, f)
+	}
+
+	fieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+func fieldName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+func parseTag(t reflect.Type, sf reflect.StructField) ([]tagRule, error) {
+	tag := sf.Tag.Get("validate")
+	if tag == "" || tag == "-" {
+		return nil, nil
+	}
+
+	var rules []tagRule
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "regex=") {
+			part, tag = tag, ""
+		} else {
+			part, tag, _ = strings.Cut(tag, ",")
+		}
+
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			continue
+		}
+
+		if _, ok := getRule(name); !ok {
+			return nil, fmt.Errorf("validation: unknown rule %q on %s.%s", name, t, sf.Name)
+		}
+		if err := checkParam(name, param); err != nil {
+			return nil, fmt.Errorf("validation: rule %q on %s.%s: %w", name, t, sf.Name, err)
+		}
+
+		rules = append(rules, tagRule{name: name, param: param})
+	}
+
+	return rules, nil
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
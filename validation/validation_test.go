@@ -0,0 +1,61 @@
+package validation
+
+import "testing"
+
+type item struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type order struct {
+	Age      int      `json:"age" validate:"min=18"`
+	Items    []item   `json:"items" validate:"min=1"`
+	Address  address  `json:"address"`
+	Billing  *address `json:"billing"`
+	Coupon   *string  `json:"coupon" validate:"len=8"`
+	Nickname string   `json:"nickname" validate:"email"`
+}
+
+func errorsOf(res *Result) map[string]string {
+	m := make(map[string]string, len(res.Errors))
+	for _, e := range res.Errors {
+		m[e.Field] = e.Rule
+	}
+	return m
+}
+
+func TestZeroValues(t *testing.T) {
+	got := errorsOf(Struct(order{}))
+
+	for field, rule := range map[string]string{"age": "min", "items": "min", "address.city": "required"} {
+		if got[field] != rule {
+			t.Errorf("%s: rule = %q, want %q", field, got[field], rule)
+		}
+	}
+	// nil pointers and empty values of other rules are optional
+	for _, field := range []string{"billing.city", "coupon", "nickname"} {
+		if rule, ok := got[field]; ok {
+			t.Errorf("%s: unexpected %q error", field, rule)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("errors = %v", got)
+	}
+}
+
+func TestSetValues(t *testing.T) {
+	coupon := "SUMMER24"
+	res := Struct(&order{Age: 30, Items: []item{{Name: "book"}}, Address: address{City: "Oslo"}, Coupon: &coupon})
+	if !res.Valid() {
+		t.Fatalf("errors = %v", res.Errors)
+	}
+
+	res = Struct(&order{Age: 30, Items: []item{{}}, Address: address{City: "Oslo"}, Billing: new(address)})
+	got := errorsOf(res)
+	if got["items[0].name"] != "required" || got["billing.city"] != "required" || len(got) != 2 {
+		t.Fatalf("errors = %v", got)
+	}
+}
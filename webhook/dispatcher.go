@@ -0,0 +1,497 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"math"
+	mathrand "math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/porebric/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	deliveryAttempts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_delivery_attempts_total",
+			Help: "The number of outbound webhook attempts, tracked by result: succeeded, failed, dead or circuit_open.",
+		},
+		[]string{"result"},
+	)
+	deliveryDuration = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name: "webhook_delivery_duration_seconds",
+			Help: "The duration of outbound webhook requests.",
+		},
+	)
+)
+
+var (
+	ErrDispatcherClosed = stderrors.New("webhook: dispatcher is closed")
+	ErrDeliveryInFlight = stderrors.New("webhook: delivery is being sent")
+)
+
+// storeTimeout bounds the store calls of a delivery, they also run while Close cancels the requests.
+const storeTimeout = 10 * time.Second
+
+type DispatcherOptions struct {
+	Store  Store
+	Client *http.Client
+	// Workers is the number of deliveries sent at the same time, 4 by default.
+	Workers int
+	// MaxAttempts before a delivery is dead-lettered, 8 by default.
+	MaxAttempts int
+	// InitialBackoff doubles after every failed attempt up to MaxBackoff, 1 second and 1 hour by default.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter randomizes every backoff by up to this fraction, 0.2 by default.
+	Jitter float64
+	// BreakerFailures consecutive failures open the circuit of a subscription for BreakerCooldown,
+	// 5 and 1 minute by default.
+	BreakerFailures int
+	BreakerCooldown time.Duration
+}
+
+// Dispatcher delivers events to subscriptions. Deliveries are signed like Receiver expects:
+// X-Webhook-Signature is "sha256=" and the hex HMAC of "<timestamp>.<body>", X-Webhook-Timestamp the unix time.
+// Close is a closer.Func: queued deliveries are sent until ctx is done, the others stay pending in the store
+// and are resumed by the next NewDispatcher.
+type Dispatcher struct {
+	opts DispatcherOptions
+
+	queue  chan string
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	closed   bool
+	timers   map[string]*time.Timer
+	breakers map[string]*breaker
+	inflight map[string]struct{}
+}
+
+type breaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+func NewDispatcher(ctx context.Context, opts DispatcherOptions) (*Dispatcher, error) {
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore()
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Hour
+	}
+	if opts.Jitter == 0 {
+		opts.Jitter = 0.2
+	}
+	if opts.BreakerFailures <= 0 {
+		opts.BreakerFailures = 5
+	}
+	if opts.BreakerCooldown <= 0 {
+		opts.BreakerCooldown = time.Minute
+	}
+
+	d := &Dispatcher{
+		opts:     opts,
+		queue:    make(chan string, 1024),
+		timers:   make(map[string]*time.Timer),
+		breakers: make(map[string]*breaker),
+		inflight: make(map[string]struct{}),
+	}
+	d.ctx, d.cancel = context.WithCancel(logger.ToContext(context.Background(), logger.FromContext(ctx)))
+
+	for i := 0; i < opts.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+
+	pending, err := opts.Store.Deliveries(ctx, DeliveryPending)
+	if err != nil {
+		d.cancel()
+		return nil, err
+	}
+	for _, p := range pending {
+		d.schedule(p.ID, time.Until(p.NextAttemptAt))
+	}
+
+	return d, nil
+}
+
+// Subscribe registers a subscription to events, all events if none are given.
+func (d *Dispatcher) Subscribe(ctx context.Context, url string, secret []byte, events ...string) (*Subscription, error) {
+	sub := &Subscription{ID: newID(), URL: url, Secret: secret, Events: events, Active: true, CreatedAt: time.Now()}
+	if err := d.opts.Store.SaveSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (d *Dispatcher) Unsubscribe(ctx context.Context, id string) error {
+	return d.opts.Store.DeleteSubscription(ctx, id)
+}
+
+// Emit stores a delivery of payload, encoded as JSON, for every subscription to event and queues them.
+func (d *Dispatcher) Emit(ctx context.Context, event string, payload any) ([]*Delivery, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	subs, err := d.opts.Store.Subscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	closed := d.closed
+	d.mu.Unlock()
+	if closed {
+		return nil, ErrDispatcherClosed
+	}
+
+	var deliveries []*Delivery
+	for _, sub := range subs {
+		if !sub.wants(event) {
+			continue
+		}
+
+		now := time.Now()
+		delivery := &Delivery{
+			ID:             newID(),
+			SubscriptionID: sub.ID,
+			Event:          event,
+			Payload:        body,
+			Status:         DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		if err := d.opts.Store.SaveDelivery(ctx, delivery); err != nil {
+			return deliveries, err
+		}
+
+		deliveries = append(deliveries, delivery)
+		d.schedule(delivery.ID, 0)
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver queues a delivery again with a fresh attempt budget, typically a dead-lettered one.
+// It returns ErrDeliveryInFlight while the delivery is being sent.
+func (d *Dispatcher) Redeliver(ctx context.Context, id string) error {
+	if !d.claim(id) {
+		return ErrDeliveryInFlight
+	}
+	defer d.release(id)
+
+	delivery, err := d.opts.Store.GetDelivery(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	delivery.Status, delivery.Attempts = DeliveryPending, 0
+	delivery.NextAttemptAt, delivery.UpdatedAt = time.Now(), time.Now()
+	if err := d.opts.Store.SaveDelivery(ctx, delivery); err != nil {
+		return err
+	}
+
+	d.schedule(id, 0)
+	return nil
+}
+
+// DeadLetters returns the deliveries that failed every attempt.
+func (d *Dispatcher) DeadLetters(ctx context.Context) ([]*Delivery, error) {
+	return d.opts.Store.Deliveries(ctx, DeliveryDead)
+}
+
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for id, t := range d.timers {
+			t.Stop()
+			delete(d.timers, id)
+		}
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return fmt.Errorf("webhook: flush deliveries: %w", ctx.Err())
+	}
+}
+
+// schedule queues the delivery after delay. Closed dispatchers leave it pending in the store.
+func (d *Dispatcher) schedule(id string, delay time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	if delay <= 0 {
+		select {
+		case d.queue <- id:
+			return
+		default:
+			delay = time.Second
+		}
+	}
+
+	if t, ok := d.timers[id]; ok {
+		t.Stop()
+	}
+	d.timers[id] = time.AfterFunc(delay, func() {
+		d.mu.Lock()
+		delete(d.timers, id)
+		d.mu.Unlock()
+
+		d.schedule(id, 0)
+	})
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+
+	for id := range d.queue {
+		if d.ctx.Err() != nil {
+			continue
+		}
+		d.deliver(id)
+	}
+}
+
+// claim marks the delivery as in flight. It reports false if it already is.
+func (d *Dispatcher) claim(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.inflight[id]; ok {
+		return false
+	}
+	d.inflight[id] = struct{}{}
+	return true
+}
+
+func (d *Dispatcher) release(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.inflight, id)
+}
+
+func (d *Dispatcher) deliver(id string) {
+	// a delivery queued twice, by a timer and Redeliver, is sent by the first worker only
+	if !d.claim(id) {
+		return
+	}
+	defer d.release(id)
+
+	ctx := d.ctx
+
+	delivery, err := d.opts.Store.GetDelivery(ctx, id)
+	if err != nil || delivery.Status != DeliveryPending {
+		return
+	}
+
+	sub, err := d.opts.Store.GetSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		delivery.LastError = "subscription not found"
+		d.finish(ctx, delivery, DeliveryDead, "dead")
+		return
+	}
+	if !sub.Active {
+		delivery.LastError = "subscription is inactive"
+		d.finish(ctx, delivery, DeliveryDead, "dead")
+		return
+	}
+
+	if wait := d.circuitWait(sub.ID); wait > 0 {
+		deliveryAttempts.WithLabelValues("circuit_open").Inc()
+		d.schedule(id, wait)
+		return
+	}
+
+	delivery.Attempts++
+	code, err := d.send(ctx, sub, delivery)
+	delivery.LastStatusCode, delivery.LastError = code, ""
+	if err != nil {
+		delivery.LastError = err.Error()
+	}
+
+	switch {
+	case err == nil && code >= 200 && code < 300:
+		d.recordResult(sub.ID, true)
+		d.finish(ctx, delivery, DeliverySucceeded, "succeeded")
+	case code == http.StatusGone:
+		sub.Active = false
+		storeCtx, cancel := storeContext(ctx)
+		err := d.opts.Store.SaveSubscription(storeCtx, sub)
+		cancel()
+		if err != nil {
+			logger.Error(ctx, err, "deactivate webhook subscription", "subscription", sub.ID)
+		}
+		d.finish(ctx, delivery, DeliveryDead, "dead")
+	case ctx.Err() != nil:
+		// cancelled by Close, the attempt does not count
+		delivery.Attempts--
+		d.save(ctx, delivery)
+	case delivery.Attempts >= d.opts.MaxAttempts:
+		d.recordResult(sub.ID, false)
+		d.finish(ctx, delivery, DeliveryDead, "dead")
+	default:
+		d.recordResult(sub.ID, false)
+		backoff := d.backoff(delivery.Attempts)
+		delivery.NextAttemptAt, delivery.UpdatedAt = time.Now().Add(backoff), time.Now()
+		d.save(ctx, delivery)
+		deliveryAttempts.WithLabelValues("failed").Inc()
+		d.schedule(id, backoff)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub *Subscription, delivery *Delivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	cfg := Receiver(sub.Secret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", delivery.ID)
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+cfg.sign(sub.Secret, cfg.Payload(timestamp, delivery.Payload)))
+
+	start := time.Now()
+	resp, err := d.opts.Client.Do(req)
+	deliveryDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) finish(ctx context.Context, delivery *Delivery, status DeliveryStatus, result string) {
+	delivery.Status, delivery.UpdatedAt = status, time.Now()
+	d.save(ctx, delivery)
+	deliveryAttempts.WithLabelValues(result).Inc()
+
+	if status == DeliveryDead {
+		logger.Warn(ctx, "webhook dead-lettered", "delivery", delivery.ID, "subscription", delivery.SubscriptionID, "attempts", delivery.Attempts, "error", delivery.LastError)
+	}
+}
+
+func (d *Dispatcher) save(ctx context.Context, delivery *Delivery) {
+	storeCtx, cancel := storeContext(ctx)
+	defer cancel()
+
+	if err := d.opts.Store.SaveDelivery(storeCtx, delivery); err != nil {
+		logger.Error(ctx, err, "save webhook delivery", "delivery", delivery.ID)
+	}
+}
+
+// storeContext keeps the values of ctx, which Close may have cancelled, with a fresh storeTimeout.
+func storeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+}
+
+// circuitWait returns how long the circuit of the subscription stays open.
+func (d *Dispatcher) circuitWait(subID string) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b, ok := d.breakers[subID]
+	if !ok {
+		return 0
+	}
+	return time.Until(b.openUntil)
+}
+
+func (d *Dispatcher) recordResult(subID string, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b, exists := d.breakers[subID]
+	if !exists {
+		b = new(breaker)
+		d.breakers[subID] = b
+	}
+
+	if ok {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= d.opts.BreakerFailures {
+		b.openUntil = time.Now().Add(d.opts.BreakerCooldown)
+		b.failures = 0
+	}
+}
+
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	backoff := float64(d.opts.InitialBackoff) * math.Pow(2, float64(attempt-1))
+	backoff = math.Min(backoff, float64(d.opts.MaxBackoff))
+	backoff *= 1 + d.opts.Jitter*(2*mathrand.Float64()-1)
+	return time.Duration(backoff)
+}
+
+// Receiver verifies webhooks sent by a Dispatcher.
+func Receiver(secrets ...[]byte) Config {
+	return Config{
+		Secrets:         secrets,
+		Hash:            sha256.New,
+		SignatureHeader: "X-Webhook-Signature",
+		SignaturePrefix: "sha256=",
+		TimestampHeader: "X-Webhook-Timestamp",
+		Payload:         defaultPayload,
+	}
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
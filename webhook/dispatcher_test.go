@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRedeliverWhileInFlight(t *testing.T) {
+	var (
+		requests atomic.Int32
+		started  = make(chan struct{}, 1)
+		release  = make(chan struct{})
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		started <- struct{}{}
+		<-release
+	}))
+	defer srv.Close()
+
+	d, err := NewDispatcher(context.Background(), DispatcherOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := d.Subscribe(ctx, srv.URL, secret); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := d.Emit(ctx, "created", map[string]string{"id": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-started
+	if err := d.Redeliver(ctx, deliveries[0].ID); err != ErrDeliveryInFlight {
+		t.Fatalf("err = %v, want ErrDeliveryInFlight", err)
+	}
+	close(release)
+
+	if err := d.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("requests = %d, want 1", n)
+	}
+}
+
+func TestDeliverToInactiveSubscription(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	store := NewMemoryStore()
+	sub := &Subscription{ID: "s1", URL: srv.URL, Secret: secret, CreatedAt: time.Now()}
+	delivery := &Delivery{ID: "d1", SubscriptionID: sub.ID, Event: "created", Payload: []byte(`{}`), Status: DeliveryPending, CreatedAt: time.Now()}
+	_ = store.SaveSubscription(ctx, sub)
+	_ = store.SaveDelivery(ctx, delivery)
+
+	d, err := NewDispatcher(ctx, DispatcherOptions{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("requests = %d, want 0", n)
+	}
+	stored, err := store.GetDelivery(ctx, delivery.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != DeliveryDead {
+		t.Fatalf("status = %s, want %s", stored.Status, DeliveryDead)
+	}
+}
+
+func TestMemoryStoreRetention(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	old := time.Now().Add(-2 * DefaultRetention)
+	_ = s.SaveDelivery(ctx, &Delivery{ID: "old", Status: DeliverySucceeded, CreatedAt: old, UpdatedAt: old})
+	_ = s.SaveDelivery(ctx, &Delivery{ID: "dead", Status: DeliveryDead, CreatedAt: old, UpdatedAt: old})
+	_ = s.SaveDelivery(ctx, &Delivery{ID: "pending", Status: DeliveryPending, CreatedAt: old, UpdatedAt: old})
+	_ = s.SaveDelivery(ctx, &Delivery{ID: "new", Status: DeliverySucceeded, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+
+	for _, id := range []string{"old", "dead"} {
+		if _, err := s.GetDelivery(ctx, id); err == nil {
+			t.Fatalf("expired delivery %s kept", id)
+		}
+	}
+	for _, id := range []string{"pending", "new"} {
+		if _, err := s.GetDelivery(ctx, id); err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+	}
+
+	s.Retention = 0
+	_ = s.SaveDelivery(ctx, &Delivery{ID: "kept", Status: DeliverySucceeded, CreatedAt: old, UpdatedAt: old})
+	if _, err := s.GetDelivery(ctx, "kept"); err != nil {
+		t.Fatalf("delivery dropped without Retention: %v", err)
+	}
+}
+
+func TestFileStoreRetention(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * DefaultRetention)
+	_ = s.SaveDelivery(ctx, &Delivery{ID: "old", Status: DeliverySucceeded, CreatedAt: old, UpdatedAt: old})
+	_ = s.SaveDelivery(ctx, &Delivery{ID: "pending", Status: DeliveryPending, CreatedAt: old, UpdatedAt: old})
+	_ = s.SaveDelivery(ctx, &Delivery{ID: "new", Status: DeliverySucceeded, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+
+	s, err = NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetDelivery(ctx, "old"); err == nil {
+		t.Fatal("expired delivery kept")
+	}
+	for _, id := range []string{"pending", "new"} {
+		if _, err := s.GetDelivery(ctx, id); err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+	}
+}
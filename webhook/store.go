@@ -0,0 +1,288 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/porebric/resty/errors"
+)
+
+type Subscription struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Secret []byte `json:"secret"`
+	// Events lists the delivered events, all if empty.
+	Events    []string  `json:"events,omitempty"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *Subscription) wants(event string) bool {
+	if !s.Active {
+		return false
+	}
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryDead is a delivery that failed every attempt. It is only retried by Redeliver.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+type Delivery struct {
+	ID             string          `json:"id"`
+	SubscriptionID string          `json:"subscription_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// Store is the delivery log and the subscription registry. Get methods return errors.ErrNotFound for unknown ids.
+type Store interface {
+	SaveSubscription(ctx context.Context, s *Subscription) error
+	GetSubscription(ctx context.Context, id string) (*Subscription, error)
+	DeleteSubscription(ctx context.Context, id string) error
+	Subscriptions(ctx context.Context) ([]*Subscription, error)
+
+	SaveDelivery(ctx context.Context, d *Delivery) error
+	GetDelivery(ctx context.Context, id string) (*Delivery, error)
+	// Deliveries returns the deliveries with status, oldest first.
+	Deliveries(ctx context.Context, status DeliveryStatus) ([]*Delivery, error)
+}
+
+// MemoryStore keeps the store in memory. Succeeded and dead deliveries are dropped once they are older than
+// Retention, a Retention of 0 keeps them.
+type MemoryStore struct {
+	Retention time.Duration
+
+	mu            sync.RWMutex
+	subscriptions map[string]*Subscription
+	deliveries    map[string]*Delivery
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		Retention:     DefaultRetention,
+		subscriptions: make(map[string]*Subscription),
+		deliveries:    make(map[string]*Delivery),
+	}
+}
+
+func (s *MemoryStore) SaveSubscription(_ context.Context, sub *Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := *sub
+	s.subscriptions[sub.ID] = &cp
+	return nil
+}
+
+func (s *MemoryStore) GetSubscription(_ context.Context, id string) (*Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sub, ok := s.subscriptions[id]
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+	cp := *sub
+	return &cp, nil
+}
+
+func (s *MemoryStore) DeleteSubscription(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subscriptions, id)
+	return nil
+}
+
+func (s *MemoryStore) Subscriptions(_ context.Context) ([]*Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs := make([]*Subscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		cp := *sub
+		subs = append(subs, &cp)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].CreatedAt.Before(subs[j].CreatedAt) })
+	return subs, nil
+}
+
+func (s *MemoryStore) SaveDelivery(_ context.Context, d *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := *d
+	s.deliveries[d.ID] = &cp
+	s.prune(time.Now())
+	return nil
+}
+
+// prune drops the deliveries that are done and older than Retention. The caller holds mu.
+func (s *MemoryStore) prune(now time.Time) {
+	if s.Retention <= 0 {
+		return
+	}
+	expired := now.Add(-s.Retention)
+	for id, d := range s.deliveries {
+		if d.Status != DeliveryPending && d.UpdatedAt.Before(expired) {
+			delete(s.deliveries, id)
+		}
+	}
+}
+
+func (s *MemoryStore) GetDelivery(_ context.Context, id string) (*Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d, ok := s.deliveries[id]
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+	cp := *d
+	return &cp, nil
+}
+
+func (s *MemoryStore) Deliveries(_ context.Context, status DeliveryStatus) ([]*Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res []*Delivery
+	for _, d := range s.deliveries {
+		if d.Status == status {
+			cp := *d
+			res = append(res, &cp)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+	return res, nil
+}
+
+// DefaultRetention is the Retention of a MemoryStore and a FileStore.
+const DefaultRetention = 7 * 24 * time.Hour
+
+// FileStore is a MemoryStore written to a JSON file after every change, for small deployments.
+type FileStore struct {
+	*MemoryStore
+
+	path string
+	mu   sync.Mutex
+}
+
+type fileState struct {
+	Subscriptions []*Subscription `json:"subscriptions"`
+	Deliveries    []*Delivery     `json:"deliveries"`
+}
+
+// NewFileStore loads path. A missing file is an empty store.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state fileState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	for _, sub := range state.Subscriptions {
+		s.subscriptions[sub.ID] = sub
+	}
+	for _, d := range state.Deliveries {
+		s.deliveries[d.ID] = d
+	}
+	return s, nil
+}
+
+func (s *FileStore) SaveSubscription(ctx context.Context, sub *Subscription) error {
+	_ = s.MemoryStore.SaveSubscription(ctx, sub)
+	return s.save()
+}
+
+func (s *FileStore) DeleteSubscription(ctx context.Context, id string) error {
+	_ = s.MemoryStore.DeleteSubscription(ctx, id)
+	return s.save()
+}
+
+func (s *FileStore) SaveDelivery(ctx context.Context, d *Delivery) error {
+	_ = s.MemoryStore.SaveDelivery(ctx, d)
+	return s.save()
+}
+
+// save drops the expired deliveries, writes the state to a synced temporary file and renames it over the store.
+func (s *FileStore) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.MemoryStore.mu.Lock()
+	s.prune(time.Now())
+
+	state := fileState{
+		Subscriptions: make([]*Subscription, 0, len(s.subscriptions)),
+		Deliveries:    make([]*Delivery, 0, len(s.deliveries)),
+	}
+	for _, sub := range s.subscriptions {
+		state.Subscriptions = append(state.Subscriptions, sub)
+	}
+	for _, d := range s.deliveries {
+		state.Deliveries = append(state.Deliveries, d)
+	}
+	data, err := json.Marshal(state)
+	s.MemoryStore.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,304 @@
+// Package webhook verifies signed webhooks received from providers.
+//
+// Verify wraps the initRequest of an endpoint: the raw body is checked before it is decoded as usual.
+//
+//	resty.Endpoint(router, webhook.Verify(webhook.GitHub(secret), initEvent), handleEvent)
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/porebric/logger"
+	"github.com/porebric/resty/errors"
+	"github.com/porebric/resty/internal/cleanup"
+	"github.com/porebric/resty/requests"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var verifications = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "webhook_verifications_total",
+		Help: "The number of verified webhooks, tracked by result.",
+	},
+	[]string{"result"},
+)
+
+// DefaultNonceTTL is the NonceTTL of webhooks without a timestamp.
+const DefaultNonceTTL = 7 * 24 * time.Hour
+
+type Encoding int
+
+const (
+	Hex Encoding = iota
+	Base64
+)
+
+type Config struct {
+	// Secrets are all accepted, so a new secret can be added before the provider switches to it.
+	Secrets [][]byte
+	// Hash is the HMAC hash, SHA-256 by default.
+	Hash     func() hash.Hash
+	Encoding Encoding
+
+	// SignatureHeader carries the signature, SignaturePrefix like "sha256=" is stripped from it.
+	SignatureHeader string
+	SignaturePrefix string
+	// TimestampHeader carries the signing time as unix seconds. Without it the timestamp is not checked.
+	TimestampHeader string
+	// Extract replaces the header options for providers putting several values into one header.
+	Extract func(r *http.Request) (signatures []string, timestamp string)
+	// Payload builds the signed content, the body or "<timestamp>.<body>" if there is a timestamp by default.
+	Payload func(timestamp string, body []byte) []byte
+
+	// Tolerance is the accepted age of the timestamp, 5 minutes by default.
+	Tolerance time.Duration
+	// Nonces rejects a webhook seen before. The nonce is the verified signature: it covers the body and the
+	// timestamp, while delivery id headers are not signed and could be changed by a replaying attacker.
+	// A nonce is forgotten again when the response, or the job of an async endpoint, is not 2xx, so the
+	// provider can retry a delivery that failed.
+	Nonces NonceCache
+	// NonceTTL is how long nonces are remembered, 2*Tolerance when the webhook has a timestamp and
+	// DefaultNonceTTL otherwise. Without a timestamp a replay is only rejected while its nonce is remembered,
+	// so configs like GitHub should keep it longer than the provider retries deliveries.
+	NonceTTL time.Duration
+
+	// MaxBodySize limits the read body, 1 MB by default.
+	MaxBodySize int64
+}
+
+// Verify returns an initRequest checking the signature of the raw body before calling init with the same body.
+// Failures answer errors.ErrorUserUnauthorized.
+func Verify[R requests.Request](cfg Config, init func(ctx context.Context, r *http.Request) (context.Context, R, error)) func(ctx context.Context, r *http.Request) (context.Context, R, error) {
+	if cfg.Hash == nil {
+		cfg.Hash = sha256.New
+	}
+	if cfg.Tolerance <= 0 {
+		cfg.Tolerance = 5 * time.Minute
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+	if cfg.Payload == nil {
+		cfg.Payload = defaultPayload
+	}
+
+	return func(ctx context.Context, r *http.Request) (context.Context, R, error) {
+		var req R
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, cfg.MaxBodySize+1))
+		if err != nil {
+			return ctx, req, errors.New(errors.ErrorInvalidRequest, "unable to read body")
+		}
+		if int64(len(body)) > cfg.MaxBodySize {
+			return ctx, req, errors.New(errors.ErrorRequestTooLarge, "")
+		}
+
+		nonce, reason := cfg.verify(ctx, r, body)
+		if reason != "" {
+			verifications.WithLabelValues(reason).Inc()
+			logger.Warn(ctx, "webhook rejected", "reason", reason)
+			return ctx, req, errors.New(errors.ErrorUserUnauthorized, "invalid webhook signature")
+		}
+		verifications.WithLabelValues("ok").Inc()
+
+		if nonce != "" {
+			cleanup.OnStatus(ctx, func(status int) error {
+				if status >= 200 && status < 300 {
+					return nil
+				}
+				return cfg.Nonces.Forget(context.WithoutCancel(ctx), nonce)
+			})
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return init(ctx, r)
+	}
+}
+
+// verify returns the reason of a rejection, empty if the webhook is valid, and the nonce it recorded.
+func (cfg *Config) verify(ctx context.Context, r *http.Request, body []byte) (nonce, reason string) {
+	var (
+		signatures []string
+		timestamp  string
+	)
+	if cfg.Extract != nil {
+		signatures, timestamp = cfg.Extract(r)
+	} else {
+		for _, v := range r.Header.Values(cfg.SignatureHeader) {
+			for _, sig := range strings.Split(v, ",") {
+				signatures = append(signatures, strings.TrimPrefix(strings.TrimSpace(sig), cfg.SignaturePrefix))
+			}
+		}
+		if cfg.TimestampHeader != "" {
+			timestamp = r.Header.Get(cfg.TimestampHeader)
+			if timestamp == "" {
+				return "", "missing_timestamp"
+			}
+		}
+	}
+	if len(signatures) == 0 {
+		return "", "missing_signature"
+	}
+
+	if timestamp != "" {
+		sec, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return "", "invalid_timestamp"
+		}
+		if age := time.Since(time.Unix(sec, 0)); age > cfg.Tolerance || age < -cfg.Tolerance {
+			return "", "expired"
+		}
+	}
+
+	payload := cfg.Payload(timestamp, body)
+	matched := ""
+	for _, secret := range cfg.Secrets {
+		expected := cfg.sign(secret, payload)
+		for _, sig := range signatures {
+			if hmac.Equal([]byte(sig), []byte(expected)) {
+				matched = sig
+			}
+		}
+	}
+	if matched == "" {
+		return "", "invalid_signature"
+	}
+
+	if cfg.Nonces != nil {
+		nonce = matched
+
+		ttl := cfg.NonceTTL
+		if ttl <= 0 && timestamp != "" {
+			ttl = 2 * cfg.Tolerance
+		} else if ttl <= 0 {
+			ttl = DefaultNonceTTL
+		}
+
+		seen, err := cfg.Nonces.Seen(ctx, nonce, ttl)
+		if err != nil {
+			logger.Error(ctx, err, "webhook nonce")
+			return "", "nonce_error"
+		}
+		if seen {
+			return "", "replay"
+		}
+	}
+
+	return nonce, ""
+}
+
+func (cfg *Config) sign(secret, payload []byte) string {
+	mac := hmac.New(cfg.Hash, secret)
+	mac.Write(payload)
+	if cfg.Encoding == Base64 {
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func defaultPayload(timestamp string, body []byte) []byte {
+	if timestamp == "" {
+		return body
+	}
+	return append([]byte(timestamp+"."), body...)
+}
+
+// GitHub verifies X-Hub-Signature-256 headers.
+func GitHub(secrets ...[]byte) Config {
+	return Config{Secrets: secrets, SignatureHeader: "X-Hub-Signature-256", SignaturePrefix: "sha256="}
+}
+
+// Stripe verifies Stripe-Signature headers, "t=<timestamp>,v1=<signature>,...".
+func Stripe(secrets ...[]byte) Config {
+	return Config{
+		Secrets: secrets,
+		Extract: func(r *http.Request) (signatures []string, timestamp string) {
+			for _, part := range strings.Split(r.Header.Get("Stripe-Signature"), ",") {
+				k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+				switch k {
+				case "t":
+					timestamp = v
+				case "v1":
+					signatures = append(signatures, v)
+				}
+			}
+			if timestamp == "" {
+				return nil, ""
+			}
+			return signatures, timestamp
+		},
+	}
+}
+
+// Slack verifies X-Slack-Signature headers, signed over "v0:<timestamp>:<body>".
+func Slack(secrets ...[]byte) Config {
+	return Config{
+		Secrets:         secrets,
+		SignatureHeader: "X-Slack-Signature",
+		SignaturePrefix: "v0=",
+		TimestampHeader: "X-Slack-Request-Timestamp",
+		Payload: func(timestamp string, body []byte) []byte {
+			return append([]byte("v0:"+timestamp+":"), body...)
+		},
+	}
+}
+
+// NonceCache remembers nonces for ttl. Seen reports whether nonce was already recorded and records it otherwise,
+// Forget removes a recorded nonce.
+type NonceCache interface {
+	Seen(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+	Forget(ctx context.Context, nonce string) error
+}
+
+// MemoryNonces drops expired nonces at most once a minute.
+type MemoryNonces struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	swept  time.Time
+}
+
+func NewMemoryNonces() *MemoryNonces {
+	return &MemoryNonces{nonces: make(map[string]time.Time)}
+}
+
+func (c *MemoryNonces) Seen(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.swept) > time.Minute {
+		for n, expires := range c.nonces {
+			if now.After(expires) {
+				delete(c.nonces, n)
+			}
+		}
+		c.swept = now
+	}
+
+	if expires, ok := c.nonces[nonce]; ok && !now.After(expires) {
+		return true, nil
+	}
+	c.nonces[nonce] = now.Add(ttl)
+	return false, nil
+}
+
+func (c *MemoryNonces) Forget(_ context.Context, nonce string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.nonces, nonce)
+	return nil
+}
@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/porebric/resty/internal/cleanup"
+)
+
+type event struct{}
+
+func (e *event) Validate() (bool, string, string) { return true, "", "" }
+func (e *event) Methods() []string                { return []string{http.MethodPost} }
+func (e *event) Path() (string, bool)             { return "/hook", false }
+func (e *event) String() string                   { return "" }
+
+func initEvent(ctx context.Context, _ *http.Request) (context.Context, *event, error) {
+	return ctx, new(event), nil
+}
+
+var secret = []byte("secret")
+
+const opened = `{"action":"opened"}`
+
+func signedRequest(timestamp time.Time, body, delivery string) *http.Request {
+	payload := body
+	if !timestamp.IsZero() {
+		payload = strconv.FormatInt(timestamp.Unix(), 10) + "." + body
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+
+	r := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(body))
+	r.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	if !timestamp.IsZero() {
+		r.Header.Set("X-Timestamp", strconv.FormatInt(timestamp.Unix(), 10))
+	}
+	if delivery != "" {
+		r.Header.Set("X-Delivery", delivery)
+	}
+	return r
+}
+
+// deliver verifies r like a request answered with status.
+func deliver(t *testing.T, verify func(context.Context, *http.Request) (context.Context, *event, error), r *http.Request, status int) error {
+	t.Helper()
+
+	ctx := cleanup.With(context.Background())
+	_, _, err := verify(ctx, r)
+	cleanup.SetStatus(ctx, status)
+	if err := cleanup.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return err
+}
+
+func TestVerifyReplayWindow(t *testing.T) {
+	cfg := Config{Secrets: [][]byte{secret}, SignatureHeader: "X-Signature", SignaturePrefix: "sha256=", TimestampHeader: "X-Timestamp", Nonces: NewMemoryNonces()}
+	verify := Verify(cfg, initEvent)
+
+	now := time.Now()
+	if err := deliver(t, verify, signedRequest(now, opened, ""), http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if err := deliver(t, verify, signedRequest(now, opened, ""), http.StatusOK); err == nil {
+		t.Fatal("replay within the tolerance accepted")
+	}
+	if err := deliver(t, verify, signedRequest(now.Add(-10*time.Minute), opened, ""), http.StatusOK); err == nil {
+		t.Fatal("expired timestamp accepted")
+	}
+
+	expires := cfg.Nonces.(*MemoryNonces).nonces
+	for _, at := range expires {
+		if d := time.Until(at); d > 10*time.Minute+time.Second {
+			t.Fatalf("nonce kept for %s, want 2*Tolerance", d)
+		}
+	}
+}
+
+// signature returns the nonce recorded for r.
+func signature(r *http.Request) string {
+	return strings.TrimPrefix(r.Header.Get("X-Signature"), "sha256=")
+}
+
+func TestVerifyNonceTTLWithoutTimestamp(t *testing.T) {
+	nonces := NewMemoryNonces()
+	cfg := Config{Secrets: [][]byte{secret}, SignatureHeader: "X-Signature", SignaturePrefix: "sha256=", Nonces: nonces}
+	verify := Verify(cfg, initEvent)
+
+	first := signedRequest(time.Time{}, opened, "d1")
+	if err := deliver(t, verify, first, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(nonces.nonces[signature(first)]); d < DefaultNonceTTL-time.Minute {
+		t.Fatalf("nonce kept for %s, want DefaultNonceTTL", d)
+	}
+
+	cfg.NonceTTL = time.Hour
+	verify = Verify(cfg, initEvent)
+	second := signedRequest(time.Time{}, `{"action":"closed"}`, "d2")
+	if err := deliver(t, verify, second, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(nonces.nonces[signature(second)]); d > time.Hour {
+		t.Fatalf("nonce kept for %s, want NonceTTL", d)
+	}
+}
+
+func TestVerifyRejectsReplayWithNewDeliveryID(t *testing.T) {
+	cfg := GitHub(secret)
+	cfg.Nonces = NewMemoryNonces()
+	verify := Verify(cfg, initEvent)
+
+	github := func(delivery string) *http.Request {
+		r := signedRequest(time.Time{}, opened, "")
+		r.Header.Set("X-Hub-Signature-256", r.Header.Get("X-Signature"))
+		r.Header.Set("X-GitHub-Delivery", delivery)
+		return r
+	}
+
+	if err := deliver(t, verify, github("d1"), http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if err := deliver(t, verify, github("d1"), http.StatusOK); err == nil {
+		t.Fatal("replay accepted")
+	}
+	if err := deliver(t, verify, github("forged"), http.StatusOK); err == nil {
+		t.Fatal("replay with a new delivery id accepted")
+	}
+}
+
+func TestVerifyForgetsNonceOfFailedDelivery(t *testing.T) {
+	cfg := Config{Secrets: [][]byte{secret}, SignatureHeader: "X-Signature", SignaturePrefix: "sha256=", Nonces: NewMemoryNonces()}
+	verify := Verify(cfg, initEvent)
+
+	if err := deliver(t, verify, signedRequest(time.Time{}, opened, "d1"), http.StatusInternalServerError); err != nil {
+		t.Fatal(err)
+	}
+	if err := deliver(t, verify, signedRequest(time.Time{}, opened, "d1"), http.StatusOK); err != nil {
+		t.Fatalf("retry of a failed delivery rejected: %v", err)
+	}
+	if err := deliver(t, verify, signedRequest(time.Time{}, opened, "d1"), http.StatusOK); err == nil {
+		t.Fatal("replay of a delivered webhook accepted")
+	}
+}